@@ -1,16 +1,28 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/ardnew/csm"
 	"github.com/ardnew/csm/log"
+	"github.com/ardnew/csm/suite"
+	"github.com/ardnew/csm/suite/assign"
+	"github.com/ardnew/csm/suite/atomic"
+	"github.com/ardnew/csm/suite/compare"
+	"github.com/ardnew/csm/suite/dialect"
+	"github.com/ardnew/csm/suite/field"
 	"github.com/ardnew/csm/suite/filter"
+	"github.com/ardnew/csm/suite/grid"
+	"github.com/ardnew/csm/suite/order"
 )
 
 var (
@@ -32,30 +44,97 @@ const (
 	printVersionFlag      = "v"
 	quietLoggingFlag      = "q"
 	logFieldDefsFlag      = "d"
+	checkOutputsFlag      = "c"
 	invertFilterFlag      = "r"
 	keepContentFlag       = "k"
 	suiteFilterFlag       = "f"
+	selectRowsFlag        = "N"
+	assignFieldFlag       = "e"
+	keepColumnsFlag       = "K"
+	dropColumnsFlag       = "X"
+	mergeDedupFlag        = "u"
+	splitByFlag           = "S"
+	sortKeysFlag          = "O"
+	diffSuiteFlag         = "D"
+	tolerancesFlag        = "T"
+	joinRowsFlag          = "J"
+	verifyResultsFlag     = "R"
+	gridAxisFlag          = "g"
+	strengthFlag          = "w"
+	reduceColumnsFlag     = "W"
+	coverageFlag          = "C"
+	delimiterFlag         = "F"
+	encodingFlag          = "E"
+	commentCharFlag       = "M"
+	lazyQuotesFlag        = "L"
+	lenientFlag           = "b"
+	dryRunFlag            = "n"
+	detailViewFlag        = "V"
+	fieldAliasFlag        = "a"
 	outputArchivePathFlag = "o"
 	extractDirPathFlag    = "x"
 	formatStringFlag      = "p"
+	searchTermFlag        = "s"
 	procTakeoffFlag       = "t"
 	procLandingFlag       = "l"
+	procMembersFlag       = "m"
 )
 
+// columnList is a list of column names (or patterns, see field.Select) given
+// as comma-separated values of one or more flags.
+type columnList []string
+
+func (l columnList) String() string { return strings.Join(l, ",") }
+
+func (l *columnList) Set(s string) error {
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			*l = append(*l, c)
+		}
+	}
+	return nil
+}
+
 func main() {
 
 	var (
 		printVersion      bool
 		quietLogging      bool
 		logFieldDefs      bool
+		checkOutputs      bool
 		invertFilter      bool
 		keepContent       bool
 		procTakeoff       bool
 		procLanding       bool
+		procMembers       string
 		suiteFilter       filter.Filters
+		selectRows        filter.Rows
+		assignField       assign.Assignments
+		keepColumns       columnList
+		dropColumns       columnList
+		mergeDedup        bool
+		splitBy           string
+		sortKeys          order.Keys
+		diffSuite         string
+		tolerances        compare.Tolerances
+		joinRows          bool
+		verifyResults     csm.Results
+		gridAxis          grid.Grid
+		strength          int
+		reduceColumns     columnList
+		coverage          bool
+		delimiter         string
+		encoding          string
+		commentChar       string
+		lazyQuotes        bool
+		lenient           bool
+		dryRun            bool
+		detailView        bool
+		fieldAlias        field.Aliases
 		outputArchivePath string
 		extractDirPath    string
 		formatString      string
+		searchTerm        string
 	)
 
 	const defaultExtractDirPath = "."
@@ -70,11 +149,12 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  The Calculator Suite Manager (csm) is a Swiss-Army Knife for analyzing, modifying, and constructing\n")
 		fmt.Fprintf(os.Stderr, "  automated test suites used by the PC-based FMPS/DAPA Calculator. The test suites are zip-compressed\n")
-		fmt.Fprintf(os.Stderr, "  archives containing two regular files at the root of the archive:\n")
+		fmt.Fprintf(os.Stderr, "  archives containing regular files named *%s at the root of the archive, for example:\n", csm.TestcaseExt)
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "    Suite.zip\n")
 		fmt.Fprintf(os.Stderr, "      |__ takeoff.testcase.csv        - Takeoff test cases\n")
 		fmt.Fprintf(os.Stderr, "      |__ landing.testcase.csv        - Landing test cases\n")
+		fmt.Fprintf(os.Stderr, "      |__ climb.testcase.csv          - Climb test cases (optional)\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "  The test cases are formatted as comma-separated values (CSV), with one test case per line. The first\n")
 		fmt.Fprintf(os.Stderr, "  line in each file contains a header row, which defines the data item corresponding to each CSV column.\n")
@@ -85,11 +165,37 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "USAGE\n")
 		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  There are three primary use cases the tool currently supports, with general usage as follows:\n")
+		fmt.Fprintf(os.Stderr, "  There are several primary use cases the tool currently supports, with general usage as follows:\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "    %s [flags] [-o output] input[.zip]                     - Extract test cases into new test suite\n", PROJECT)
 		fmt.Fprintf(os.Stderr, "    %s [flags] -d input[.zip]                              - Display test suite table schema\n", PROJECT)
 		fmt.Fprintf(os.Stderr, "    %s [flags] [-p format] input[.zip] [-- columns]        - Print formatted values of test cases\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] -V [-N rows] [-f expr] input[.zip]          - Print selected test cases vertically\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] -e expr -o output input[.zip]               - Derive new test suite with modified values\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] -K|-X columns -o output input[.zip]         - Keep, drop, or reorder output columns\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] [-u] -o output input[.zip] input[.zip] ...  - Merge test suites into one test suite\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] -S N|column -o output input[.zip]           - Split test suite into multiple suites\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] -g axis [-e expr] -o output template[.zip]  - Generate test cases from a parameter grid\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] -g axis -w t -o output template[.zip]       - Generate t-way (e.g., pairwise) test cases\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] -W columns [-w t] -o output input[.zip]     - Reduce test suite to t-way covering subset\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] [-T tol] -D new[.zip] old[.zip]             - Compare test cases of two test suites\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] [-T tol] -R results.csv input[.zip]         - Verify calculator results (exit status)\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] -C input[.zip] [-- columns]                 - Report coverage of values and pairs\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] -c input[.zip]                              - Check outputs agree with extended values\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] -s term input[.zip]                         - Search fields by name, label, or value\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "    %s [flags] -n [-o output] input[.zip]                  - Print plan of any of the above (dry run)\n", PROJECT)
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  Each of the trailing columns may be a field name or alias, a glob (e.g., '[out]V*'), a regular\n")
+		fmt.Fprintf(os.Stderr, "  expression delimited by slashes (e.g., '/^GROSS/'), or one of the selectors @inputs, @outputs,\n")
+		fmt.Fprintf(os.Stderr, "  @extended, or @all. Globs, regular expressions, and selectors expand to columns in header order.\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  Each sort key may be followed by modifiers :asc or :desc (direction), and :num, :str, or :enum\n")
+		fmt.Fprintf(os.Stderr, "  (comparison). By default, enumerated fields sort in code order, and all others sort numerically\n")
+		fmt.Fprintf(os.Stderr, "  if both values are numbers, or lexically otherwise.\n")
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "  Each grid axis is given as NAME=item,item,..., where each item is a value (or enumerated label),\n")
+		fmt.Fprintf(os.Stderr, "  a range lo:hi[:step] of values, or * for every value of an enumerated field. The template may be\n")
+		fmt.Fprintf(os.Stderr, "  any test suite, including one with only header rows; its test cases are replaced.\n")
 		fmt.Fprintf(os.Stderr, "\n")
 		fmt.Fprintf(os.Stderr, "FLAGS\n")
 		fmt.Fprintf(os.Stderr, "\n")
@@ -102,20 +208,72 @@ func main() {
 		"Suppress printing non-error log messages (quiet)")
 	cli.BoolVar(&logFieldDefs, logFieldDefsFlag, false,
 		"List the field definitions parsed from headers")
+	cli.BoolVar(&checkOutputs, checkOutputsFlag, false,
+		"Verify each output agrees with its extended-precision value")
 	cli.BoolVar(&invertFilter, invertFilterFlag, false,
 		"Invert matching semantics (select non-matching records)")
 	cli.BoolVar(&keepContent, keepContentFlag, false,
 		"Keep filtered files in extraction directory after suite creation")
+	cli.StringVar(&procMembers, procMembersFlag, "",
+		"Process only test case files in comma-separated `list` (prefix name with ! to exclude)")
 	cli.BoolVar(&procTakeoff, procTakeoffFlag, true,
-		"Process takeoff test cases")
+		"Process takeoff test cases (same as -"+procMembersFlag+" !takeoff if false)")
 	cli.BoolVar(&procLanding, procLandingFlag, true,
-		"Process landing test cases")
+		"Process landing test cases (same as -"+procMembersFlag+" !landing if false)")
 	cli.Var(&suiteFilter, suiteFilterFlag,
 		"Select records matching `expression` (logical-OR of each flag given)")
+	cli.Var(&selectRows, selectRowsFlag,
+		"Select records by test case `numbers` (e.g., 1,4-7; logical-OR with -"+suiteFilterFlag+")")
+	cli.Var(&assignField, assignFieldFlag,
+		"Modify each selected record (every record, if none are selected) per `expression` (e.g., RCR=WET, WEIGHT+=10000, WEIGHT*=1.1)")
+	cli.Var(&keepColumns, keepColumnsFlag,
+		"Write only the given `columns` to output suite, in the order given (comma-separated)")
+	cli.Var(&dropColumns, dropColumnsFlag,
+		"Remove the given `columns` from output suite (comma-separated)")
+	cli.BoolVar(&mergeDedup, mergeDedupFlag, false,
+		"Omit duplicate records when merging multiple input suites")
+	cli.StringVar(&splitBy, splitByFlag, "",
+		"Split output suite into `N` shards, or one shard per value of the named column")
+	cli.Var(&sortKeys, sortKeysFlag,
+		"Sort selected records by comma-separated `keys` (e.g., MDS,WEIGHT:desc,TEMP:num)")
+	cli.StringVar(&diffSuite, diffSuiteFlag, "",
+		"Compare input suite with the test suite at `path` (added, removed, changed cases)")
+	cli.Var(&tolerances, tolerancesFlag,
+		"Compare outputs within `tolerance` (e.g., VR=0.05, *=0.1%; comma-separated)")
+	cli.BoolVar(&joinRows, joinRowsFlag, false,
+		"Pair compared test cases by row number instead of input values")
+	cli.Var(&verifyResults, verifyResultsFlag,
+		"Verify calculator results in CSV `file` (as [member=]file) against expected outputs")
+	cli.Var(&gridAxis, gridAxisFlag,
+		"Generate test cases from the product of each grid `axis` (e.g., MDS=*, RCR=1,2, WEIGHT=180000:220000:10000)")
+	cli.IntVar(&strength, strengthFlag, 0,
+		"Cover every combination of values of every `t` columns (t-way; 2 for all-pairs) with -"+gridAxisFlag+" or -"+reduceColumnsFlag)
+	cli.Var(&reduceColumns, reduceColumnsFlag,
+		"Reduce input suite to a subset covering every t-way combination of `columns` (comma-separated)")
+	cli.BoolVar(&coverage, coverageFlag, false,
+		"Report values and pairs of values of trailing columns (default enumerated inputs) found in test cases")
+	cli.StringVar(&delimiter, delimiterFlag, "",
+		"Read and write fields separated by `char` (e.g., ';' or 'tab'; default detected)")
+	cli.StringVar(&encoding, encodingFlag, "",
+		"Read and write text in `encoding` (utf-8 or windows-1252; default detected)")
+	cli.StringVar(&commentChar, commentCharFlag, "",
+		"Ignore (and retain) lines beginning with `char` (default '#' if found in the first 4 KiB)")
+	cli.BoolVar(&lazyQuotes, lazyQuotesFlag, false,
+		"Allow quotes in unquoted fields and non-doubled quotes in quoted fields")
+	cli.BoolVar(&lenient, lenientFlag, false,
+		"Skip malformed rows, writing each to <name>"+csm.RejectExt+" with its line number and reason")
+	cli.BoolVar(&dryRun, dryRunFlag, false,
+		"Print what would be extracted, retained, written, and removed without writing any files")
+	cli.BoolVar(&detailView, detailViewFlag, false,
+		"Print each selected record vertically, one field per line")
+	cli.Var(&fieldAlias, fieldAliasFlag,
+		"Define field name `alias` (as alias=name, or a file with one alias=name per line)")
 	cli.StringVar(&outputArchivePath, outputArchivePathFlag, "",
 		"Create output test suite (.zip) at `filepath`")
 	cli.StringVar(&extractDirPath, extractDirPathFlag, defaultExtractDirPath,
 		"Extract and save filtered test suites to `dirpath`")
+	cli.StringVar(&searchTerm, searchTermFlag, "",
+		"Search field names, aliases, labels and values for `term` (substring, glob, or /regexp/)")
 	cli.StringVar(&formatString, formatStringFlag, "",
 		"Print each column named in trailing arguments per format `string`")
 
@@ -156,10 +314,26 @@ func main() {
 		log.Output = ioutil.Discard
 	}
 
+	// remove any incomplete output files if interrupted
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-interrupt
+		atomic.AbortAll()
+		log.Msg(log.Error, "error", "interrupted (%s), removed incomplete output", sig)
+		exit(19)
+	}()
+
+	given, err := dialectOptions(delimiter, encoding, commentChar, lazyQuotes)
+	if nil != err {
+		log.Msg(log.Error, "error", "%s", err.Error())
+		exit(1)
+	}
+
 	if len(cliArg) == 0 {
 		log.Msg(log.Error, "error",
 			"no input test suite (.zip file or directory) provided. see -h for usage.")
-		os.Exit(1)
+		exit(1)
 	}
 
 	if "" == outputArchivePath && "" == extractDirPath {
@@ -181,69 +355,271 @@ func main() {
 			log.Msg(log.Warn, "warning", "using default output file name: %q",
 				outputArchivePath)
 		}
+	}
+	if dryRun {
+		// everything is extracted and filtered in a temporary directory instead,
+		// which is removed on exit.
+		dir, err := os.MkdirTemp("", "csm-dry-run-")
+		if nil != err {
+			log.Msg(log.Error, "error", "os.MkdirTemp(): %s", err.Error())
+			exit(2)
+		}
+		dryRunDir = dir
+	} else if "" != outputArchivePath {
 		err := os.MkdirAll(filepath.Dir(outputArchivePath), os.ModePerm)
 		if nil != err {
 			log.Msg(log.Error, "error", "os.MkdirAll(): %s", err.Error())
-			os.Exit(2)
+			exit(2)
 		}
 	}
-	if filepath.Dir(outputArchivePath) != extractDirPath {
+	if !dryRun && filepath.Dir(outputArchivePath) != extractDirPath {
 		if err := os.MkdirAll(extractDirPath, os.ModePerm); nil != err {
 			log.Msg(log.Error, "error", "os.MkdirAll(): %s", err.Error())
-			os.Exit(2)
+			exit(2)
 		}
 	}
 
+	var procMember []string
+	for _, m := range strings.Split(procMembers, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			procMember = append(procMember, m)
+		}
+	}
+	if !procTakeoff {
+		procMember = append(procMember, "!"+csm.TakeoffName)
+	}
+	if !procLanding {
+		procMember = append(procMember, "!"+csm.LandingName)
+	}
+
 	path := cli.Arg(0)
 	{
-		p, err := csm.New(path, extractDirPath, outputArchivePath)
-		if nil != err {
-			log.Msg(log.Error, "error", "csm.New(): %s", err.Error())
-			os.Exit(3)
-		}
-		if info, err := os.Stat(path); nil != err {
-			log.Msg(log.Error, "error", "os.Stat(): %s", err.Error())
-			os.Exit(4)
-		} else if info.IsDir() {
-			if err := p.Replicate(); nil != err {
-				log.Msg(log.Error, "error", "csm.Replicate(): %s", err.Error())
-				os.Exit(5)
+		p := prepare(path, extractDirPath, outputArchivePath, given)
+		if cli.NArg() > 1 {
+			// each additional suite is extracted into its own subdirectory and then
+			// merged with the first.
+			src := make([]*csm.CSM, cli.NArg()-1)
+			for i, a := range cli.Args()[1:] {
+				src[i] = prepare(a,
+					filepath.Join(extractDirPath, csm.MergeBase, strconv.Itoa(i+1)), "", given)
 			}
-		} else {
-			if p.Stale() {
-				if err := p.Extract(); nil != err {
-					log.Msg(log.Error, "error", "csm.Extract(): %s", err.Error())
-					os.Exit(6)
-				}
+			if err := p.Merge(mergeDedup, src...); nil != err {
+				log.Msg(log.Error, "error", "csm.Merge(): %s", err.Error())
+				exit(11)
 			}
 		}
 		opts := csm.Options{
 			LogFieldDefs: logFieldDefs,
+			CheckOutputs: checkOutputs,
 			InvertFilter: invertFilter,
 			KeepContent:  keepContent,
 			Filters:      suiteFilter,
+			Assigns:      assignField,
+			Rows:         selectRows,
+			DetailView:   detailView,
+			Aliases:      fieldAlias,
+			SearchTerm:   searchTerm,
 			FormatString: formatString,
 			FormatCols:   colArg,
-			ProcTakeoff:  procTakeoff,
-			ProcLanding:  procLanding,
+			Members:      procMember,
+			KeepCols:     keepColumns,
+			DropCols:     dropColumns,
+			SplitBy:      splitBy,
+			SortKeys:     sortKeys,
+			Tolerances:   tolerances,
+			JoinRows:     joinRows,
+			Results:      verifyResults,
+			Grid:         gridAxis,
+			ReduceCols:   reduceColumns,
+			Strength:     strength,
+			Coverage:     coverage,
+			Lenient:      lenient,
+		}
+		if strength < 0 {
+			log.Msg(log.Error, "error", "invalid coverage strength (-%s): %d",
+				strengthFlag, strength)
+			exit(1)
+		}
+		if len(opts.Grid) > 0 {
+			if err := p.Generate(opts); nil != err {
+				log.Msg(log.Error, "error", "csm.Generate(): %s", err.Error())
+				exit(16)
+			}
+		}
+		if len(opts.ReduceCols) > 0 {
+			if err := p.Reduce(opts); nil != err {
+				log.Msg(log.Error, "error", "csm.Reduce(): %s", err.Error())
+				exit(17)
+			}
+		}
+		// every test case generated, modified, merged, split, projected, or sorted
+		// is retained unless selected otherwise
+		opts.RetainAll = len(opts.Grid) > 0 || len(opts.ReduceCols) > 0 ||
+			len(opts.Assigns) > 0 || cli.NArg() > 1 || "" != opts.SplitBy ||
+			len(opts.KeepCols) > 0 || len(opts.DropCols) > 0 || len(opts.SortKeys) > 0
+		if "" != diffSuite {
+			q := prepare(diffSuite, filepath.Join(extractDirPath, csm.DiffBase), "", given)
+			if err := p.Diff(q, opts); nil != err {
+				log.Msg(log.Error, "error", "csm.Diff(): %s", err.Error())
+				exit(13)
+			}
+			log.Msg(log.Info, "exit", "ok!")
+			exit(0)
+		}
+		if len(opts.Results) > 0 {
+			pass, err := p.Verify(opts)
+			if nil != err {
+				log.Msg(log.Error, "error", "csm.Verify(): %s", err.Error())
+				exit(14)
+			}
+			if !pass {
+				exit(15)
+			}
+			log.Msg(log.Info, "exit", "ok!")
+			exit(0)
+		}
+		if opts.Coverage {
+			if err := p.Coverage(opts); nil != err {
+				log.Msg(log.Error, "error", "csm.Coverage(): %s", err.Error())
+				exit(18)
+			}
+			log.Msg(log.Info, "exit", "ok!")
+			exit(0)
+		}
+		if "" != opts.SearchTerm {
+			if err := p.Search(opts); nil != err {
+				log.Msg(log.Error, "error", "csm.Search(): %s", err.Error())
+				excerpt(err)
+				exit(10)
+			}
+			log.Msg(log.Info, "exit", "ok!")
+			exit(0)
 		}
 		if err := p.Filter(opts); nil != err {
 			log.Msg(log.Error, "error", "csm.Filter(): %s", err.Error())
-			os.Exit(7)
+			excerpt(err)
+			exit(7)
 		}
 		if !opts.LogFieldDefs {
 			if "" != outputArchivePath {
-				if err := p.Compress(opts); nil != err {
+				// the filtered files are removed even if no output suite is written
+				if "" != opts.SplitBy {
+					if err := p.Split(opts); nil != err {
+						log.Msg(log.Error, "error", "csm.Split(): %s", err.Error())
+						p.Cleanup(opts)
+						exit(12)
+					}
+				} else if err := p.Compress(opts); nil != err {
 					log.Msg(log.Error, "error", "csm.Compress(): %s", err.Error())
-					os.Exit(8)
+					p.Cleanup(opts)
+					exit(8)
 				}
+			} else if "" != splitBy {
+				log.Msg(log.Warn, "warning", "ignoring -%s without output suite (-%s)",
+					splitByFlag, outputArchivePathFlag)
 			}
 			if err := p.Cleanup(opts); nil != err {
 				log.Msg(log.Error, "error", "csm.Cleanup(): %s", err.Error())
-				os.Exit(9)
+				exit(9)
 			}
 		}
 	}
 
 	log.Msg(log.Info, "exit", "ok!")
+	exit(0)
+}
+
+// dryRunDir is the temporary directory into which each test suite is extracted
+// instead, if dry run.
+var dryRunDir string
+
+// dryRuns is the number of test suites extracted into dryRunDir.
+var dryRuns int
+
+// exit removes dryRunDir, if any, and exits with the given status code.
+func exit(code int) {
+	if "" != dryRunDir {
+		os.RemoveAll(dryRunDir)
+	}
+	os.Exit(code)
+}
+
+// excerpt prints the offending line of each test case file error in err.
+func excerpt(err error) {
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range j.Unwrap() {
+			excerpt(e)
+		}
+		return
+	}
+	var se *suite.Error
+	if errors.As(err, &se) {
+		fmt.Fprint(os.Stderr, se.Excerpt())
+	}
+}
+
+// dialectOptions returns the dialect options given on the command line.
+func dialectOptions(delimiter, encoding, comment string, lazyQuotes bool) (dialect.Options, error) {
+	opts := dialect.Options{LazyQuotes: lazyQuotes}
+	char := func(name, s string) (rune, error) {
+		switch strings.ToLower(s) {
+		case "":
+			return 0, nil
+		case "tab", `\t`:
+			return '\t', nil
+		}
+		if r := []rune(s); len(r) == 1 && r[0] != '"' && r[0] != '\r' && r[0] != '\n' {
+			return r[0], nil
+		}
+		return 0, fmt.Errorf("invalid %s character: %q", name, s)
+	}
+	var err error
+	if opts.Comma, err = char("delimiter", delimiter); nil != err {
+		return opts, err
+	}
+	if opts.Comment, err = char("comment", comment); nil != err {
+		return opts, err
+	}
+	if opts.Comma != 0 && opts.Comma == opts.Comment {
+		return opts, fmt.Errorf("delimiter and comment characters must differ: %q", delimiter)
+	}
+	if encoding != "" {
+		if opts.Encoding, err = dialect.ParseEncoding(encoding); nil != err {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// prepare extracts (or replicates) the test suite at path, exiting on error.
+func prepare(path, extractDirPath, outputArchivePath string, given dialect.Options) *csm.CSM {
+	p, err := csm.New(path, extractDirPath, outputArchivePath, given)
+	if nil != err {
+		log.Msg(log.Error, "error", "csm.New(): %s", err.Error())
+		exit(3)
+	}
+	info, err := os.Stat(path)
+	if nil != err {
+		log.Msg(log.Error, "error", "os.Stat(): %s", err.Error())
+		exit(4)
+	}
+	if "" != dryRunDir {
+		// the suite is always extracted, but only into the temporary directory
+		dryRuns += 1
+		p.DryRun(filepath.Join(dryRunDir, strconv.Itoa(dryRuns)))
+	}
+	if info.IsDir() {
+		if err := p.Replicate(); nil != err {
+			log.Msg(log.Error, "error", "csm.Replicate(): %s", err.Error())
+			exit(5)
+		}
+	} else {
+		if p.Stale() {
+			if err := p.Extract(); nil != err {
+				log.Msg(log.Error, "error", "csm.Extract(): %s", err.Error())
+				exit(6)
+			}
+		}
+	}
+	return p
 }
@@ -0,0 +1,180 @@
+package csm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/ardnew/csm/log"
+	"github.com/ardnew/csm/suite/dialect"
+	"github.com/ardnew/csm/suite/field"
+	"github.com/ardnew/csm/suite/record"
+)
+
+// Coverage prints, for each selected file, every value of each column named by
+// opts.FormatCols (see field.Select), or of each enumerated input if none are
+// named, and every combination of values of each pair of those columns. Each
+// is listed with the number of test cases containing it (+), or as missing (-)
+// if no test case contains it. The values of an enumerated column include all
+// of its coded values; those of any other column include only values found.
+func (c *CSM) Coverage(opts Options) error {
+	log.Msg(log.Info, "coverage", "%q", c.csvPath)
+	member, err := c.members(opts)
+	if nil != err {
+		return err
+	}
+	for _, m := range member {
+		if opts.Selected(m) {
+			if err := coverMember(filepath.Join(c.csvPath, m), m, c.dialect, opts); nil != err {
+				return fmt.Errorf("%s: %w", m, err)
+			}
+		}
+	}
+	return nil
+}
+
+func coverMember(path, name string, d dialect.Options, opts Options) error {
+	it, err := record.Open(path, d)
+	if nil != err {
+		return err
+	}
+	defer it.Close()
+	def := it.Def()
+	def.AddAliases(opts.Aliases)
+
+	var col []field.Spec
+	if len(opts.FormatCols) > 0 {
+		var unknown []string
+		col, unknown = def.Select(opts.FormatCols)
+		for _, u := range unknown {
+			log.Msg(log.Warn, "coverage", "ignoring unknown field: %s: %q", name, u)
+		}
+	} else {
+		for _, f := range def.Columns() {
+			if _, ok := def.Enum(f.Name); ok && f.Kind == field.KindInput {
+				col = append(col, field.Spec{Name: f.Name, Col: f.Col})
+			}
+		}
+	}
+	if len(col) == 0 {
+		log.Msg(log.Warn, "coverage", "no columns selected: %s", name)
+		return nil
+	}
+
+	type pair [2]string
+	one := make([]map[string]int, len(col))
+	two := make([][]map[pair]int, len(col))
+	for i := range col {
+		one[i] = map[string]int{}
+		two[i] = make([]map[pair]int, len(col))
+		for j := i + 1; j < len(col); j++ {
+			two[i][j] = map[pair]int{}
+		}
+	}
+	for it.Next() {
+		val := def.Values(col, it.Record().Values)
+		for i, v := range val {
+			one[i][v] += 1
+			for j := i + 1; j < len(val); j++ {
+				two[i][j][pair{v, val[j]}] += 1
+			}
+		}
+	}
+	if err := it.Err(); nil != err {
+		return err
+	}
+
+	// the domain of each column includes every value found, and every coded
+	// value if enumerated
+	dom := make([][]string, len(col))
+	for i, s := range col {
+		var found []string
+		for v := range one[i] {
+			found = append(found, v)
+		}
+		sort.Slice(found, func(a, b int) bool { return lessValue(found[a], found[b]) })
+		if e, ok := def.Enum(s.Name); ok {
+			dom[i] = e.Codes()
+			for _, v := range found {
+				if _, ok := e.Label(v); !ok {
+					dom[i] = append(dom[i], v)
+				}
+			}
+		} else {
+			dom[i] = found
+		}
+	}
+
+	fmt.Fprintln(os.Stdout, "==", name)
+	var valFound, valTotal, pairFound, pairTotal int
+	for i, s := range col {
+		n := 0
+		for _, v := range dom[i] {
+			if one[i][v] > 0 {
+				n += 1
+			}
+		}
+		valFound, valTotal = valFound+n, valTotal+len(dom[i])
+		fmt.Fprintf(os.Stdout, "  %s: %d of %d values (%s)\n",
+			s.Name, n, len(dom[i]), percent(n, len(dom[i])))
+		for _, v := range dom[i] {
+			printCoverage(one[i][v], def.Decode(s.Name, v))
+		}
+	}
+	for i := range col {
+		for j := i + 1; j < len(col); j++ {
+			n, t := 0, len(dom[i])*len(dom[j])
+			for _, p := range two[i][j] {
+				if p > 0 {
+					n += 1
+				}
+			}
+			pairFound, pairTotal = pairFound+n, pairTotal+t
+			fmt.Fprintf(os.Stdout, "  %s, %s: %d of %d pairs (%s)\n",
+				col[i].Name, col[j].Name, n, t, percent(n, t))
+			for _, a := range dom[i] {
+				for _, b := range dom[j] {
+					printCoverage(two[i][j][pair{a, b}],
+						def.Decode(col[i].Name, a)+", "+def.Decode(col[j].Name, b))
+				}
+			}
+		}
+	}
+	log.Msg(log.Info, "coverage", "%s: %d of %d values (%s), %d of %d pairs (%s)",
+		name, valFound, valTotal, percent(valFound, valTotal),
+		pairFound, pairTotal, percent(pairFound, pairTotal))
+	return nil
+}
+
+func printCoverage(count int, desc string) {
+	if count > 0 {
+		fmt.Fprintf(os.Stdout, "    + %-40s %d\n", desc, count)
+	} else {
+		fmt.Fprintf(os.Stdout, "    - %s\n", desc)
+	}
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "n/a"
+	}
+	return strconv.FormatFloat(100*float64(n)/float64(total), 'f', 1, 64) + "%"
+}
+
+// lessValue orders numbers numerically, before all other values, which are
+// ordered lexically.
+func lessValue(a, b string) bool {
+	x, xe := strconv.ParseFloat(a, 64)
+	y, ye := strconv.ParseFloat(b, 64)
+	switch {
+	case nil == xe && nil == ye:
+		return x < y
+	case nil == xe:
+		return true
+	case nil == ye:
+		return false
+	}
+	return a < b
+}
@@ -1,17 +1,28 @@
 package csm
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ardnew/csm/log"
 	"github.com/ardnew/csm/suite"
+	"github.com/ardnew/csm/suite/assign"
+	"github.com/ardnew/csm/suite/atomic"
 	"github.com/ardnew/csm/suite/cache"
+	"github.com/ardnew/csm/suite/compare"
+	"github.com/ardnew/csm/suite/dialect"
 	"github.com/ardnew/csm/suite/field"
 	"github.com/ardnew/csm/suite/filter"
+	"github.com/ardnew/csm/suite/grid"
+	"github.com/ardnew/csm/suite/order"
+	"github.com/ardnew/csm/suite/record"
 
 	"github.com/mholt/archiver/v3"
 )
@@ -19,10 +30,19 @@ import (
 const (
 	ArchiveExt  = ".zip"
 	CsvBase     = ".csv"
-	TakeoffName = "takeoff.testcase.csv"
-	LandingName = "landing.testcase.csv"
-	OutPrefix   = "[out]"
-	ExtPrefix   = "[outext]"
+	MergeBase   = ".merge"
+	SplitBase   = ".split"
+	DiffBase    = ".diff"
+	GridBase    = ".grid"
+	ReduceBase  = ".reduce"
+	TestcaseExt = ".testcase.csv"
+	RejectExt   = ".rejects.csv"
+	TakeoffName = "takeoff" + TestcaseExt
+	LandingName = "landing" + TestcaseExt
+	OutPrefix   = field.OutPrefix
+	ExtPrefix   = field.ExtPrefix
+
+	searchSamples = 3 // number of distinct values shown per search result
 )
 
 type CSM struct {
@@ -30,27 +50,110 @@ type CSM struct {
 	csvPath string // path to files extracted from input zip
 	outPath string // output zip file
 	xtcPath string // path to files compressed into output zip
+	dryPath string // xtcPath replaced by a temporary directory, if dry run
+	dialect dialect.Options
 	cache   *cache.Cache
 }
 
 type Options struct {
 	LogFieldDefs bool
+	CheckOutputs bool
 	InvertFilter bool
+	RetainAll    bool // retain every record if none are selected by Filters or Rows
 	KeepContent  bool
 	Filters      filter.Filters
+	Assigns      assign.Assignments
+	Rows         filter.Rows
+	DetailView   bool
+	Aliases      field.Aliases
+	SearchTerm   string
 	FormatString string
 	FormatCols   []string
-	ProcTakeoff  bool
-	ProcLanding  bool
+	Members      []string
+	KeepCols     []string
+	DropCols     []string
+	SplitBy      string
+	SortKeys     order.Keys
+	Tolerances   compare.Tolerances
+	JoinRows     bool
+	Results      Results
+	Grid         grid.Grid
+	ReduceCols   []string
+	Strength     int
+	Coverage     bool
+	Lenient      bool
+}
+
+// Selected reports whether the given member of the test suite is selected for
+// processing by Members. Each element of Members is the name of a member, with
+// or without the TestcaseExt suffix (e.g., "climb"), to be processed. If an
+// element begins with '!', that member is excluded instead. All members are
+// selected if Members does not include any member by name.
+func (o Options) Selected(member string) bool {
+	base := strings.TrimSuffix(member, TestcaseExt)
+	any, include := false, false
+	for _, m := range o.Members {
+		exclude := strings.HasPrefix(m, "!")
+		m = strings.TrimSuffix(strings.TrimPrefix(m, "!"), TestcaseExt)
+		if exclude {
+			if m == base {
+				return false
+			}
+			continue
+		}
+		any = true
+		include = include || m == base
+	}
+	return !any || include
+}
+
+// Members returns the name of each test case file in the given directory. The
+// well-known takeoff and landing files, if present, are listed first, followed
+// by all others in lexical order.
+func Members(dir string) ([]string, error) {
+	match, err := filepath.Glob(filepath.Join(dir, "*"+TestcaseExt))
+	if nil != err {
+		return nil, err
+	}
+	name := make([]string, 0, len(match))
+	for _, m := range match {
+		if info, err := os.Stat(m); nil == err && info.Mode().IsRegular() {
+			name = append(name, filepath.Base(m))
+		}
+	}
+	sortMembers(name)
+	return name, nil
+}
+
+func sortMembers(name []string) {
+	rank := func(name string) int {
+		switch name {
+		case TakeoffName:
+			return 0
+		case LandingName:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(name, func(i, j int) bool {
+		if ri, rj := rank(name[i]), rank(name[j]); ri != rj {
+			return ri < rj
+		}
+		return name[i] < name[j]
+	})
 }
 
-func New(arcPath, xtcPath, outPath string) (*CSM, error) {
+// New returns a CSM extracting the test suite at arcPath into xtcPath, and
+// writing any output suite to outPath. The dialect of each test case file is
+// detected except for the properties given by d.
+func New(arcPath, xtcPath, outPath string, d dialect.Options) (*CSM, error) {
 	csvPath := filepath.Join(xtcPath, CsvBase)
 	return &CSM{
 		arcPath: arcPath,
 		csvPath: csvPath,
 		outPath: outPath,
 		xtcPath: xtcPath,
+		dialect: d,
 		cache:   cache.New(arcPath, csvPath),
 	}, nil
 }
@@ -92,6 +195,37 @@ func (c *CSM) Extract() error {
 	return nil
 }
 
+// DryRun logs whether the input suite would be extracted (or replicated) into
+// the extraction directory of c, and then replaces that directory with dir, so
+// that all subsequent operations write only into dir. Compress, Split, and
+// Cleanup instead log the output files they would write or remove.
+func (c *CSM) DryRun(dir string) {
+	if info, err := os.Stat(c.arcPath); nil == err && info.IsDir() {
+		log.Msg(log.Info, "dry-run", "would replicate %q -> %q", c.arcPath, c.csvPath)
+	} else if c.Stale() {
+		log.Msg(log.Info, "dry-run", "would extract %q -> %q", c.arcPath, c.csvPath)
+	} else {
+		log.Msg(log.Info, "dry-run", "would skip extracting %q (up to date: %q)",
+			c.arcPath, c.csvPath)
+	}
+	c.dryPath = c.xtcPath
+	c.xtcPath = dir
+	c.csvPath = filepath.Join(dir, CsvBase)
+	c.cache = cache.New(c.arcPath, c.csvPath)
+}
+
+// planned returns the path that would be written in place of the given path in
+// the extraction directory of c, if dry run.
+func (c *CSM) planned(path string) string {
+	if "" == c.dryPath {
+		return path
+	}
+	if rel, err := filepath.Rel(c.xtcPath, path); nil == err {
+		return filepath.Join(c.dryPath, rel)
+	}
+	return path
+}
+
 func (c *CSM) Replicate() error {
 	log.Msg(log.Info, "replicate", "%q -> %q", c.arcPath, c.csvPath)
 	if err := os.RemoveAll(c.csvPath); nil != err {
@@ -114,105 +248,798 @@ func (c *CSM) Replicate() error {
 		_, err = io.Copy(d, s)
 		return err
 	}
-	if err := cp(filepath.Join(c.arcPath, LandingName),
-		filepath.Join(c.csvPath, LandingName)); nil != err {
+	member, err := Members(c.arcPath)
+	if nil != err {
+		return err
+	}
+	if len(member) == 0 {
+		return fmt.Errorf("no test case files (*%s) found: %s", TestcaseExt, c.arcPath)
+	}
+	for _, m := range member {
+		if err := cp(filepath.Join(c.arcPath, m),
+			filepath.Join(c.csvPath, m)); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merge concatenates the test cases of each given suite with those of c, which
+// must be extracted or replicated beforehand. Each file in the merged suite has
+// the header row of the first suite containing that file, and the records of
+// all other suites are reordered to match. If dedup is true, records identical
+// to any preceding record are omitted. The merged suite replaces the content of
+// c for all subsequent operations.
+func (c *CSM) Merge(dedup bool, src ...*CSM) error {
+	mergePath := filepath.Join(c.xtcPath, MergeBase, CsvBase)
+	log.Msg(log.Info, "merge", "%d suites -> %q", len(src)+1, mergePath)
+	if err := os.RemoveAll(mergePath); nil != err {
 		return err
 	}
-	if err := cp(filepath.Join(c.arcPath, TakeoffName),
-		filepath.Join(c.csvPath, TakeoffName)); nil != err {
+	if err := os.MkdirAll(mergePath, os.ModePerm); nil != err {
 		return err
 	}
+
+	all := append([]*CSM{c}, src...)
+	var member []string
+	for _, s := range all {
+		m, err := Members(s.csvPath)
+		if nil != err {
+			return err
+		}
+		for _, name := range m {
+			if !contains(member, name) {
+				member = append(member, name)
+			}
+		}
+	}
+	sortMembers(member)
+
+	for _, m := range member {
+		n, dup, err := mergeMember(filepath.Join(mergePath, m), m, dedup, all)
+		if nil != err {
+			return fmt.Errorf("%s: %w", m, err)
+		}
+		log.Msg(log.Info, "merge", "%s: %d records (%d duplicates omitted)", m, n, dup)
+	}
+
+	c.csvPath = mergePath
 	return nil
 }
 
+func mergeMember(path, name string, dedup bool, all []*CSM) (count, dup int, err error) {
+	f, err := os.Create(path)
+	if nil != err {
+		return 0, 0, err
+	}
+	defer func() {
+		if cerr := f.Close(); nil == err {
+			err = cerr
+		}
+	}()
+	var w *dialect.Writer // written in the dialect of the first suite
+
+	var def *field.FieldDef
+	seen := map[string]bool{}
+	for _, s := range all {
+		it, err := record.Open(filepath.Join(s.csvPath, name), s.dialect)
+		if nil != err {
+			if os.IsNotExist(err) {
+				continue // not every suite must contain every file
+			}
+			return count, dup, err
+		}
+		idx := make([]int, len(it.Header()))
+		if nil == def {
+			def = it.Def()
+			for i := range idx {
+				idx[i] = i
+			}
+			w = it.Dialect().NewWriter(f)
+			if err := w.Write(it.Header()); nil != err {
+				it.Close()
+				return count, dup, err
+			}
+		} else if idx, err = def.Compatible(it.Def()); nil != err {
+			it.Close()
+			return count, dup, fmt.Errorf("incompatible header: %s: %w", s.arcPath, err)
+		}
+		for it.Next() {
+			val := it.Record().Values
+			rec := make([]string, len(idx))
+			for i, j := range idx {
+				if j < len(val) {
+					rec[i] = val[j]
+				}
+			}
+			if dedup {
+				key := strings.Join(rec, "\x1f")
+				if seen[key] {
+					dup += 1
+					continue
+				}
+				seen[key] = true
+			}
+			if err := w.Write(rec); nil != err {
+				it.Close()
+				return count, dup, err
+			}
+			count += 1
+		}
+		err = it.Err()
+		it.Close()
+		if nil != err {
+			return count, dup, fmt.Errorf("%s: %w", s.arcPath, err)
+		}
+	}
+	if nil == w {
+		return count, dup, nil
+	}
+	w.Flush()
+	return count, dup, w.Error()
+}
+
+// Compress writes the filtered test case files into the output archive, which
+// replaces any existing archive only if successful.
 func (c *CSM) Compress(opts Options) error {
-	log.Msg(log.Info, "compress", "%q -> %q", c.xtcPath, c.outPath)
-	if err := os.Remove(c.outPath); nil != err && !os.IsNotExist(err) {
+	log.Msg(log.Info, "compress", "%q -> %q", c.planned(c.xtcPath), c.outPath)
+	path, err := c.outputs()
+	if nil != err {
+		return err
+	}
+	if "" != c.dryPath {
+		c.plan(c.outPath, path)
+		return nil
+	}
+	return archive(path, c.outPath)
+}
+
+// plan logs that the output archive at out would be written with the given
+// files, and whether it would replace an existing file.
+func (c *CSM) plan(out string, file []string) {
+	verb := "create"
+	if _, err := os.Stat(out); nil == err {
+		verb = "replace"
+	}
+	name := make([]string, len(file))
+	for i, f := range file {
+		name[i] = filepath.Base(f)
+	}
+	log.Msg(log.Info, "dry-run", "would %s %q (%s)", verb, out, strings.Join(name, ", "))
+}
+
+// archive writes the given files into a new zip archive replacing the file at
+// out, which is left unmodified if an error occurs.
+func archive(file []string, out string) error {
+	return atomic.WriteFile(out, func(tmp string) error {
+		z := archiver.NewZip()
+		z.OverwriteExisting = true // the temporary file
+		return z.Archive(file, tmp)
+	})
+}
+
+// Split writes the filtered test cases into multiple output archives instead
+// of the single archive written by Compress. If opts.SplitBy is a positive
+// integer N, the records of each file are divided into N contiguous shards of
+// roughly equal size, named "<output>.<k>.zip" for k = 1..N. Otherwise,
+// opts.SplitBy names a column, and each distinct value of that column gets its
+// own shard, named "<output>.<column>-<value>.zip" (suffixed with "-2", "-3",
+// etc., if another value has the same name once sanitized). Every shard
+// contains every file of the test suite, each with its original header row.
+// A file without the named column is contained whole in every shard, but at
+// least one file must have it.
+func (c *CSM) Split(opts Options) error {
+	path, err := c.outputs()
+	if nil != err {
+		return err
+	}
+	base := strings.TrimSuffix(c.outPath, ArchiveExt)
+	splitPath := filepath.Join(c.xtcPath, SplitBase)
+	if err := os.RemoveAll(splitPath); nil != err {
+		return err
+	}
+	atomic.Track(splitPath)
+	defer atomic.Remove(splitPath)
+
+	var shard []string         // name of each shard, in order created
+	count, byCount := 0, false // number of shards, if split by count
+	if n, err := strconv.Atoi(opts.SplitBy); nil == err {
+		if n < 1 {
+			return fmt.Errorf("invalid number of shards: %d", n)
+		}
+		count, byCount = n, true
+		w := log.Digits(n)
+		for k := 1; k <= n; k++ {
+			shard = append(shard, fmt.Sprintf("%0*d", w, k))
+		}
+	}
+	// files without the named column are not split
+	whole := map[string]bool{}
+	if !byCount {
+		found := false
+		for _, p := range path {
+			ok, err := hasColumn(p, c.dialect, opts.SplitBy)
+			if nil != err {
+				return err
+			}
+			whole[p], found = !ok, found || ok
+		}
+		if !found {
+			return fmt.Errorf("split column is not defined in any test case file: %q",
+				opts.SplitBy)
+		}
+	}
+	log.Msg(log.Info, "split", "%q -> %q", c.planned(c.xtcPath), base+".*"+ArchiveExt)
+
+	// distinct values may be sanitized to the same shard name (or to names that
+	// differ only in case), so each value gets its own name, suffixed if needed.
+	shardOf := map[string]string{} // shard name of each value
+	valueOf := map[string]string{} // value of each (lowercase) shard name
+	taken := func(s string) bool {
+		_, ok := valueOf[strings.ToLower(s)]
+		return ok
+	}
+	nameFor := func(v string) string {
+		if s, ok := shardOf[v]; ok {
+			return s
+		}
+		name := sanitize(opts.SplitBy) + "-" + sanitize(v)
+		s := name
+		for k := 2; taken(s); k++ {
+			s = fmt.Sprintf("%s-%d", name, k)
+		}
+		if s != name {
+			log.Msg(log.Warn, "split", "values %q and %q have the same shard name, using %q",
+				valueOf[strings.ToLower(name)], v, s)
+		}
+		shardOf[v], valueOf[strings.ToLower(s)] = s, v
+		return s
+	}
+
+	for _, p := range path {
+		name := filepath.Base(p)
+		if whole[p] {
+			log.Msg(log.Warn, "split", "%s: unknown field %q, adding all records to every shard",
+				name, opts.SplitBy)
+			continue
+		}
+		var key func(*record.Record) (string, error)
+		if byCount {
+			n, err := countRecords(p, c.dialect)
+			if nil != err {
+				return err
+			}
+			key = func(r *record.Record) (string, error) {
+				return shard[(r.Row-1)*count/n], nil
+			}
+		} else {
+			key = func(r *record.Record) (string, error) {
+				v, err := r.String(opts.SplitBy)
+				if nil != err {
+					return "", fmt.Errorf("%s: %w", name, err)
+				}
+				return nameFor(v), nil
+			}
+		}
+		created, err := splitMember(p, splitPath, c.dialect, key)
+		if nil != err {
+			return err
+		}
+		for _, s := range created {
+			if !contains(shard, s) {
+				shard = append(shard, s)
+			}
+		}
+	}
+
+	for _, s := range shard {
+		dir := filepath.Join(splitPath, s)
+		if err := os.MkdirAll(dir, os.ModePerm); nil != err {
+			return err
+		}
+		var file []string
+		for _, p := range path {
+			if whole[p] {
+				file = append(file, p)
+				continue
+			}
+			f := filepath.Join(dir, filepath.Base(p))
+			// files without any records in this shard still get a header row
+			if _, err := os.Stat(f); os.IsNotExist(err) {
+				if err := copyHeader(p, f, c.dialect); nil != err {
+					return err
+				}
+			}
+			file = append(file, f)
+		}
+		out := base + "." + s + ArchiveExt
+		if "" != c.dryPath {
+			c.plan(out, file)
+			continue
+		}
+		if err := archive(file, out); nil != err {
+			return err
+		}
+		log.Msg(log.Info, "split", "%q", out)
+	}
+	return nil
+}
+
+// splitMember writes each record of the test case file at path into a file of
+// the same name in the subdirectory of dir given by key. It returns each key
+// in the order first encountered.
+func splitMember(path, dir string, d dialect.Options, key func(*record.Record) (string, error)) ([]string, error) {
+	it, err := record.Open(path, d)
+	if nil != err {
+		return nil, err
+	}
+	defer it.Close()
+
+	type output struct {
+		f *os.File
+		w *dialect.Writer
+	}
+	var created []string
+	out := map[string]*output{}
+	defer func() {
+		for _, o := range out {
+			o.f.Close()
+		}
+	}()
+	for it.Next() {
+		k, err := key(it.Record())
+		if nil != err {
+			return nil, err
+		}
+		o, ok := out[k]
+		if !ok {
+			d := filepath.Join(dir, k)
+			if err := os.MkdirAll(d, os.ModePerm); nil != err {
+				return nil, err
+			}
+			f, err := os.Create(filepath.Join(d, filepath.Base(path)))
+			if nil != err {
+				return nil, err
+			}
+			o = &output{f: f, w: it.Dialect().NewWriter(f)}
+			out[k] = o
+			created = append(created, k)
+			if err := o.w.Write(it.Header()); nil != err {
+				return nil, err
+			}
+		}
+		if err := o.w.Write(it.Record().Values); nil != err {
+			return nil, err
+		}
+	}
+	if err := it.Err(); nil != err {
+		return nil, err
+	}
+	for _, o := range out {
+		if o.w.Flush(); nil != o.w.Error() {
+			return nil, o.w.Error()
+		}
+	}
+	return created, nil
+}
+
+func countRecords(path string, d dialect.Options) (int, error) {
+	it, err := record.Open(path, d)
+	if nil != err {
+		return 0, err
+	}
+	defer it.Close()
+	n := 0
+	for it.Next() {
+		n += 1
+	}
+	return n, it.Err()
+}
+
+func copyHeader(src, dst string, d dialect.Options) error {
+	it, err := record.Open(src, d)
+	if nil != err {
 		return err
 	}
-	takeoffPath := filepath.Join(c.xtcPath, TakeoffName)
-	landingPath := filepath.Join(c.xtcPath, LandingName)
-	return archiver.Archive([]string{takeoffPath, landingPath}, c.outPath)
+	defer it.Close()
+	f, err := os.Create(dst)
+	if nil != err {
+		return err
+	}
+	defer f.Close()
+	w := it.Dialect().NewWriter(f)
+	if err := w.Write(it.Header()); nil != err {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// hasColumn reports whether the header row of the test case file at path
+// defines the named column.
+func hasColumn(path string, d dialect.Options, name string) (bool, error) {
+	it, err := record.Open(path, d)
+	if nil != err {
+		return false, err
+	}
+	defer it.Close()
+	_, ok := it.Def().ColForCsv(name)
+	return ok, nil
+}
+
+// sanitize replaces each rune of str that is not safe for use in a file name.
+func sanitize(str string) string {
+	if str == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '.', r == '_', r == '+', r == '-':
+			return r
+		}
+		return '_'
+	}, str)
 }
 
 func (c *CSM) Cleanup(opts Options) error {
 	if !opts.KeepContent {
-		takeoffPath := filepath.Join(c.xtcPath, TakeoffName)
-		landingPath := filepath.Join(c.xtcPath, LandingName)
-		log.Msg(log.Info, "cleanup", "%+v", enquote(takeoffPath, landingPath))
-		if err := os.Remove(takeoffPath); nil != err && !os.IsNotExist(err) {
+		path, err := c.outputs()
+		if nil != err {
 			return err
 		}
-		if err := os.Remove(landingPath); nil != err && !os.IsNotExist(err) {
-			return err
+		if "" != c.dryPath {
+			for i, p := range path {
+				path[i] = c.planned(p)
+			}
+			log.Msg(log.Info, "dry-run", "would remove %+v", enquote(path...))
+			return nil
+		}
+		log.Msg(log.Info, "cleanup", "%+v", enquote(path...))
+		for _, p := range path {
+			if err := os.Remove(p); nil != err && !os.IsNotExist(err) {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// outputs returns the path of each filtered test case file.
+func (c *CSM) outputs() ([]string, error) {
+	member, err := Members(c.csvPath)
+	if nil != err {
+		return nil, err
+	}
+	path := make([]string, len(member))
+	for i, m := range member {
+		path[i] = filepath.Join(c.xtcPath, m)
+	}
+	return path, nil
+}
+
 func (c *CSM) Filter(opts Options) error {
 
-	var takeoffDef, landingDef *field.FieldDef
+	if !opts.LogFieldDefs {
+		log.Msg(log.Info, "filter", "%q -> %q", c.planned(c.csvPath), c.planned(c.xtcPath))
+	}
+
+	member, err := c.members(opts)
+	if nil != err {
+		return err
+	}
+
+	// each file is processed concurrently, buffering everything it prints so
+	// that the output of each file is printed in order once all have finished.
+	jobs := make([]*job, len(member))
+	for i, m := range member {
+		jobs[i] = &job{name: m, proc: opts.Selected(m)}
+	}
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *job) {
+			defer wg.Done()
+			c.filterFile(j, opts)
+		}(j)
+	}
+	wg.Wait()
+
+	for _, j := range jobs {
+		j.out.flush()
+		err = errors.Join(err, j.err)
+	}
+	if nil != err {
+		return err
+	}
+
+	var f, p, m int
+	var fs, ms []string
+	for _, j := range jobs {
+		base := strings.TrimSuffix(j.name, TestcaseExt)
+		f, p, m = f+j.filtered, p+j.processed, m+j.mismatch
+		fs = append(fs, fmt.Sprintf("%d of %d %s", j.filtered, j.processed, base))
+		ms = append(ms, fmt.Sprintf("%d %s", j.mismatch, base))
+	}
+	if !opts.LogFieldDefs {
+		log.Msg(
+			log.Info, "filter", "retained %d of %d records (%s)",
+			f, p, strings.Join(fs, ", "),
+		)
+	}
+	if opts.CheckOutputs {
+		log.Msg(
+			log.Info, "check", "found %d inconsistent outputs (%s)",
+			m, strings.Join(ms, ", "),
+		)
+	}
+	if opts.Lenient {
+		var r int
+		var rs []string
+		for _, j := range jobs {
+			if nil != j.rej && j.rej.count > 0 {
+				r += j.rej.count
+				rs = append(rs, fmt.Sprintf("%d %s -> %q",
+					j.rej.count, strings.TrimSuffix(j.name, TestcaseExt), c.planned(j.rej.path)))
+			}
+		}
+		if r > 0 {
+			log.Msg(
+				log.Warn, "reject", "rejected %d malformed records (%s)",
+				r, strings.Join(rs, ", "),
+			)
+		}
+	}
+
+	return nil
+}
+
+// members returns the name of each test case file to be processed, warning of
+// any member selected by name that does not exist.
+func (c *CSM) members(opts Options) ([]string, error) {
+	member, err := Members(c.csvPath)
+	if nil != err {
+		return nil, err
+	}
+	if len(member) == 0 {
+		return nil, fmt.Errorf("no test case files (*%s) found: %s", TestcaseExt, c.arcPath)
+	}
+	for _, m := range opts.Members {
+		m = strings.TrimPrefix(m, "!")
+		if !contains(member, m) && !contains(member, m+TestcaseExt) {
+			log.Msg(log.Warn, "member", "ignoring unknown test case file: %q", m)
+		}
+	}
+	return member, nil
+}
+
+func (c *CSM) filterFile(j *job, opts Options) {
 
-	var takeoffOut, landingOut string
+	// each file must evaluate filters against its own field definitions
+	opts.Filters = append(filter.Filters(nil), opts.Filters...)
+	opts.Assigns = append(assign.Assignments(nil), opts.Assigns...)
+
+	var def *field.FieldDef
+	var out string
 	if !opts.LogFieldDefs {
-		log.Msg(log.Info, "filter", "%q -> %q", c.csvPath, c.xtcPath)
-		takeoffOut = filepath.Join(c.xtcPath, TakeoffName)
-		landingOut = filepath.Join(c.xtcPath, LandingName)
+		out = filepath.Join(c.xtcPath, j.name)
+	}
+
+	// sorted records are first written to a temporary file, along with their
+	// row numbers, from which they are printed and written in order.
+	j.sort = j.proc && len(opts.SortKeys) > 0 && "" != out
+	final := out
+	if j.sort {
+		out = filepath.Join(c.xtcPath, "."+j.name+".unsorted")
+		atomic.Track(out)
+		defer atomic.Remove(out)
 	}
 
-	var tf, tp, lf, lp int
 	var defHandler, rowHandler suite.RecordHandler
+	if j.proc {
+		defHandler = c.fieldDefHandler(j, &opts, &def) // header row handler
+		rowHandler = c.recordHandler(j, &opts, &def)   // data row handler
+	} else {
+		defHandler = func(r []string) (rec []string, skip, stop bool, err error) {
+			// keep header row
+			if err := j.project(&opts, field.NewDef(r, OutPrefix, ExtPrefix)); nil != err {
+				return r, true, true, err
+			}
+			return j.apply(r), false, false, nil
+		}
+		rowHandler = func(r []string) (rec []string, skip, stop bool, err error) {
+			return r, false, true, nil // stop at first data row
+		}
+	}
+	var rejHandler suite.RejectHandler
+	if opts.Lenient {
+		j.rej = &rejects{path: filepath.Join(c.xtcPath,
+			strings.TrimSuffix(j.name, TestcaseExt)+RejectExt)}
+		if err := os.Remove(j.rej.path); nil != err && !os.IsNotExist(err) {
+			j.err = err
+			return
+		}
+		defer func() { j.err = errors.Join(j.err, j.rej.close()) }()
+		rejHandler = func(line int, raw []byte, reason error) error {
+			j.row += 1 // rejected rows are numbered like any other
+			j.msg(log.Warn, "reject", "%s: line %d: %s", j.name, line, reason)
+			return j.rej.write(line, raw, reason)
+		}
+	}
+	s, err := suite.New(
+		filepath.Join(c.csvPath, j.name), // source file
+		out,                              // output file
+		c.dialect,                        // given dialect properties
+		defHandler,                       // header row handler
+		rowHandler,                       // data row handler
+		rejHandler)                       // malformed row handler
+	if nil != err {
+		// the output file, if any, is left unmodified
+		var e *suite.Error
+		if errors.As(err, &e) {
+			e.Suite, e.File = c.arcPath, j.name
+		}
+		j.err = err
+		return
+	}
+	j.filtered, j.processed = s.Filtered, s.Processed
+	if j.sort && nil != def {
+		if err := c.sortFile(j, &opts, def, s.Dialect, out, final); nil != err {
+			j.err = fmt.Errorf("%s: %w", j.name, err)
+		}
+	}
+}
+
+// sortFile sorts the records of the temporary file at path, as written by the
+// handlers of a sorted job, and then prints and writes them to out in the
+// given dialect.
+func (c *CSM) sortFile(j *job, opts *Options, def *field.FieldDef, d dialect.Dialect, path, out string) error {
+	cmp, unknown := opts.SortKeys.Compare(def)
+	for _, k := range unknown {
+		j.msg(log.Warn, "sort", "ignoring sort key on unknown field: %s: %q", j.name, k)
+	}
+	if err := order.File(path, d.Options(), cmp, order.DefaultLimit); nil != err {
+		return err
+	}
 
-	keepHandler :=
-		func(r []string) (rec []string, skip, stop bool) {
-			return r, false, false
+	in, err := os.Open(path)
+	if nil != err {
+		return err
+	}
+	defer in.Close()
+	td, br, err := dialect.Detect(in, d.Options())
+	if nil != err {
+		return err
+	}
+	r := td.NewReader(br)
+	r.FieldsPerRecord = -1
+
+	f, err := atomic.Create(out)
+	if nil != err {
+		return err
+	}
+	defer f.Abort() // unless committed
+	w := d.NewWriter(f)
+
+	for line := 0; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
 		}
-	stopHandler :=
-		func(r []string) (rec []string, skip, stop bool) {
-			return r, false, true
+		if nil != err {
+			return err
+		}
+		// the last column of each record is its row number
+		n := len(rec) - 1
+		if line > 0 {
+			row, _ := strconv.Atoi(rec[n])
+			c.printRecord(j, opts, def, row, rec[:n])
 		}
+		if err := w.Write(j.apply(rec[:n])); nil != err {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); nil != err {
+		return err
+	}
+	return f.Commit()
+}
 
-	if opts.ProcTakeoff {
-		defHandler = c.fieldDefHandler(TakeoffName, &opts, &takeoffDef) // header row handler
-		rowHandler = c.recordHandler(TakeoffName, &opts, &takeoffDef)   // data row handler
-	} else {
-		defHandler = keepHandler
-		rowHandler = stopHandler
-	}
-	ts, err := suite.New(
-		filepath.Join(c.csvPath, TakeoffName), // source file
-		takeoffOut,                            // output file
-		defHandler,                            // header row handler
-		rowHandler)                            // data row handler
+func (c *CSM) Search(opts Options) error {
+	match, err := field.NewMatcher(opts.SearchTerm)
 	if nil != err {
 		return err
 	}
-	tf, tp = ts.Filtered, ts.Processed
+	log.Msg(log.Info, "search", "%q in %q", opts.SearchTerm, c.csvPath)
 
-	if opts.ProcLanding {
-		defHandler = c.fieldDefHandler(LandingName, &opts, &landingDef) // header row handler
-		rowHandler = c.recordHandler(LandingName, &opts, &landingDef)   // data row handler
-	} else {
-		defHandler = keepHandler
-		rowHandler = stopHandler
-	}
-	ls, err := suite.New(
-		filepath.Join(c.csvPath, LandingName), // source file
-		landingOut,                            // output file
-		defHandler,                            // header row handler
-		rowHandler)                            // data row handler
+	member, err := c.members(opts)
 	if nil != err {
 		return err
 	}
-	lf, lp = ls.Filtered, ls.Processed
+	var name []string
+	for _, m := range member {
+		if opts.Selected(m) {
+			name = append(name, m)
+		}
+	}
 
-	if !opts.LogFieldDefs {
-		log.Msg(
-			log.Info, "filter", "retained %d of %d records (%d of %d takeoff, %d of %d landing)",
-			tf+lf, tp+lp, tf, tp, lf, lp,
-		)
+	total := 0
+	for _, n := range name {
+		var def *field.FieldDef
+		var col []field.Column
+		var enum []field.Enum
+		var found []bool
+		var sample [][]string
+
+		define := func(r []string) (rec []string, skip, stop bool, err error) {
+			def = field.NewDef(r, OutPrefix, ExtPrefix)
+			def.AddAliases(opts.Aliases)
+			col = def.Columns()
+			enum = make([]field.Enum, len(col))
+			found = make([]bool, len(col))
+			sample = make([][]string, len(col))
+			for i, f := range col {
+				enum[i], _ = def.Enum(f.Name)
+				found[i] = match(f.Name)
+				for _, a := range def.AliasesFor(f.Name) {
+					found[i] = found[i] || match(a)
+				}
+				for _, label := range enum[i] {
+					found[i] = found[i] || match(label)
+				}
+			}
+			return r, false, false, nil
+		}
+		handle := func(r []string) (rec []string, skip, stop bool, err error) {
+			for i := range col {
+				if i >= len(r) {
+					break
+				}
+				v := r[i]
+				if len(sample[i]) < searchSamples && !contains(sample[i], v) {
+					sample[i] = append(sample[i], v)
+				}
+				if !found[i] {
+					label, _ := enum[i].Label(v)
+					found[i] = match(v) || ("" != label && match(label))
+				}
+			}
+			return r, true, false, nil
+		}
+		var reject suite.RejectHandler
+		if opts.Lenient {
+			reject = func(line int, raw []byte, reason error) error {
+				log.Msg(log.Warn, "reject", "%s: line %d: %s", n, line, reason)
+				return nil
+			}
+		}
+		if _, err := suite.New(filepath.Join(c.csvPath, n), "", c.dialect, define, handle, reject); nil != err {
+			return err
+		}
+		if nil == def {
+			continue // empty file
+		}
+
+		w := log.Digits(len(col))
+		fmt.Fprintln(os.Stdout, "==", n)
+		for i, f := range col {
+			if !found[i] {
+				continue
+			}
+			total += 1
+			fmt.Fprintf(os.Stdout, "  %s %0*d %q", f.Kind, w, f.Col, f.Name)
+			if as := def.AliasesFor(f.Name); len(as) > 0 {
+				fmt.Fprintf(os.Stdout, " (%s)", strings.Join(as, ", "))
+			}
+			val := make([]string, len(sample[i]))
+			for j, v := range sample[i] {
+				val[j] = def.Decode(f.Name, v)
+			}
+			fmt.Fprintf(os.Stdout, ": %s\n", strings.Join(val, ", "))
+		}
 	}
+	log.Msg(log.Info, "search", "found %d matching fields", total)
 
 	return nil
 }
@@ -241,13 +1068,13 @@ func (c *CSM) formatRecord(format string, col []field.Spec, rec []string) (strin
 			// auto-built format string is simply space-delimited elements
 			elf := make([]string, len(col))
 			for i, s := range col {
-				arg[i] = rec[s.Col] // convert string to interface{} for Sprintf
+				arg[i] = valueAt(rec, s.Col) // convert string to interface{} for Sprintf
 				elf[i] = "%s"
 			}
 			format = strings.Join(elf, " ")
 		} else {
 			for i, s := range col {
-				arg[i] = rec[s.Col] // convert string to interface{} for Sprintf
+				arg[i] = valueAt(rec, s.Col) // convert string to interface{} for Sprintf
 			}
 		}
 	}
@@ -256,46 +1083,76 @@ func (c *CSM) formatRecord(format string, col []field.Spec, rec []string) (strin
 }
 
 func (c *CSM) fieldDefHandler(
-	name string, opts *Options, def **field.FieldDef) suite.RecordHandler {
+	j *job, opts *Options, def **field.FieldDef) suite.RecordHandler {
 
-	return func(r []string) (rec []string, skip, stop bool) {
+	name := j.name
+	return func(r []string) (rec []string, skip, stop bool, err error) {
 		*def = field.NewDef(r, OutPrefix, ExtPrefix)
+		for _, a := range (*def).AddAliases(opts.Aliases) {
+			j.msg(log.Warn, "alias", "ignoring alias of unknown field: %s: %s=%q",
+				name, a, opts.Aliases[a])
+		}
 		if opts.LogFieldDefs {
-			(*def).Log(os.Stdout, name)
-			return r, false, true // stop processing after reading field def header
+			(*def).Log(j.out.to(os.Stdout), name)
+			return r, false, true, nil // stop processing after reading field def header
+		}
+		if err := j.project(opts, *def); nil != err {
+			return r, true, true, err
 		}
 		for i := range opts.Filters {
 			_, ok := (*def).ColForCsv(opts.Filters[i].Field())
 			opts.Filters[i].SetValid(ok)
 			if !ok {
-				log.Msg(log.Warn, "filter", "ignoring filter on unknown field: %s: %q",
+				j.msg(log.Warn, "filter", "ignoring filter on unknown field: %s: %q",
 					name, opts.Filters[i].Field())
 			}
 		}
+		for i := range opts.Assigns {
+			_, ok := (*def).ColForCsv(opts.Assigns[i].Field())
+			opts.Assigns[i].SetValid(ok)
+			if !ok {
+				j.msg(log.Warn, "assign", "ignoring assignment to unknown field: %s: %q",
+					name, opts.Assigns[i].Field())
+			}
+		}
 
-		(*def).Selected = make([]field.Spec, 0, len(opts.FormatCols))
-		for _, c := range opts.FormatCols {
-			n, ok := (*def).ColForCsv(c)
+		var unknown []string
+		(*def).Selected, unknown = (*def).Select(opts.FormatCols)
+		for _, c := range unknown {
+			j.msg(log.Warn, "format", "ignoring unknown field: %s: %q", name, c)
+		}
+		if !opts.DetailView {
+			h, ok := c.formatRecord(opts.FormatString, (*def).Selected, nil)
 			if ok {
-				(*def).Selected = append((*def).Selected, field.Spec{Name: c, Col: n})
-			} else {
-				log.Msg(log.Warn, "format", "ignoring unknown field: %s: %q", name, c)
+				j.raw(h + "\n")
 			}
 		}
-		h, ok := c.formatRecord(opts.FormatString, (*def).Selected, nil)
-		if ok {
-			log.Raw(h + "\n")
-		}
 
-		return r, false, false
+		if j.sort {
+			// projected after sorting, with an extra column for row numbers
+			return append(r, ""), false, false, nil
+		}
+		return j.apply(r), false, false, nil
 	}
 }
 
 func (c *CSM) recordHandler(
-	name string, opts *Options, def **field.FieldDef) suite.RecordHandler {
+	j *job, opts *Options, def **field.FieldDef) suite.RecordHandler {
 
-	return func(r []string) (rec []string, skip, stop bool) {
+	name := j.name
+	return func(r []string) (rec []string, skip, stop bool, err error) {
+		j.row += 1
+		row := j.row
+		if opts.CheckOutputs {
+			for _, m := range (*def).Mismatches(r) {
+				j.msg(log.Warn, "check", "%s: row %d: %s", name, row, m)
+				j.mismatch += 1
+			}
+		}
 		match := 0
+		if opts.Rows.Contains(row) {
+			match += 1
+		}
 		for _, f := range opts.Filters {
 			if f.Valid() {
 				value, _ := (*def).ValueForCsv(f.Field(), r)
@@ -306,18 +1163,185 @@ func (c *CSM) recordHandler(
 		}
 		// skip this case if no criteria matched
 		skip = match == 0
-		if opts.InvertFilter {
+		if opts.RetainAll && len(opts.Filters) == 0 && len(opts.Rows) == 0 {
+			// nothing selected, so everything is retained
+			skip = false
+		} else if opts.InvertFilter {
 			// skip this case if any criteria matched
 			skip = !skip
 		}
 		if !skip {
-			h, ok := c.formatRecord(opts.FormatString, (*def).Selected, r)
-			if ok {
-				log.Raw(h + "\n")
+			for _, a := range opts.Assigns {
+				if a.Valid() {
+					if err := a.Apply(*def, r); nil != err {
+						col, _ := (*def).ColForCsv(a.Field())
+						return r, true, true, &suite.Error{
+							Col:   col,
+							Field: (*def).Resolve(a.Field()),
+							Value: valueAt(r, col),
+							Err:   err,
+						}
+					}
+				}
 			}
+			if j.sort {
+				// printed and projected after sorting
+				return append(r, strconv.Itoa(row)), skip, stop, nil
+			}
+			c.printRecord(j, opts, *def, row, r)
+		}
+		return j.apply(r), skip, stop, nil
+	}
+}
+
+func (c *CSM) printRecord(j *job, opts *Options, def *field.FieldDef, row int, r []string) {
+	if opts.DetailView {
+		def.LogRecord(j.out.to(log.Output), j.name, row, r)
+	} else if h, ok := c.formatRecord(opts.FormatString, def.Selected, r); ok {
+		j.raw(h + "\n")
+	}
+}
+
+// job is a single file processed by Filter.
+type job struct {
+	name      string // name of the file in the test suite
+	proc      bool   // process records, or copy header row only
+	out       output // everything printed while processing the file
+	processed int
+	filtered  int
+	mismatch  int
+	row       int   // test case number of the last data row, including rejected
+	col       []int // columns written to output file (nil for all)
+	sort      bool  // records are sorted before they are written
+	rej       *rejects
+	err       error
+}
+
+// rejects is the file of malformed rows rejected from a test case file, which
+// is created when the first row is rejected.
+type rejects struct {
+	path  string
+	count int
+	f     *os.File
+	w     *dialect.Writer
+}
+
+func (r *rejects) write(line int, raw []byte, reason error) error {
+	if nil == r.f {
+		f, err := os.Create(r.path)
+		if nil != err {
+			return err
+		}
+		r.f, r.w = f, dialect.Default.NewWriter(f)
+		if err := r.w.Write([]string{"LINE", "REASON", "ROW"}); nil != err {
+			return err
+		}
+	}
+	r.count += 1
+	return r.w.Write([]string{strconv.Itoa(line), reason.Error(), string(raw)})
+}
+
+func (r *rejects) close() error {
+	if nil == r.f {
+		return nil
+	}
+	r.w.Flush()
+	return errors.Join(r.w.Error(), r.f.Close())
+}
+
+// project determines the columns written to the output file, and verifies
+// the resulting header row is well-formed.
+func (j *job) project(opts *Options, def *field.FieldDef) error {
+	if len(opts.KeepCols) == 0 && len(opts.DropCols) == 0 {
+		return nil
+	}
+	var unknown []string
+	j.col, unknown = def.Project(opts.KeepCols, opts.DropCols)
+	for _, c := range unknown {
+		j.msg(log.Warn, "project", "ignoring unknown field: %s: %q", j.name, c)
+	}
+	if len(j.col) == 0 {
+		return errors.New("invalid column projection: no columns selected")
+	}
+	names := make([]string, len(def.Columns()))
+	for _, c := range def.Columns() {
+		names[c.Col] = c.Name
+	}
+	if err := field.Validate(j.apply(names), OutPrefix, ExtPrefix); nil != err {
+		return fmt.Errorf("invalid column projection: %w", err)
+	}
+	return nil
+}
+
+// apply returns the given record with only those columns determined by
+// project, or the record itself if no projection was requested.
+func (j *job) apply(r []string) []string {
+	if nil == j.col {
+		return r
+	}
+	rec := make([]string, len(j.col))
+	for i, c := range j.col {
+		if c < len(r) {
+			rec[i] = r[c]
+		}
+	}
+	return rec
+}
+
+func (j *job) msg(level int, prompt string, format string, args ...interface{}) {
+	log.Fmsg(j.out.to(log.Writer(level)), level, prompt, format, args...)
+}
+
+func (j *job) raw(format string, args ...interface{}) {
+	fmt.Fprintf(j.out.to(log.Output), format, args...)
+}
+
+// output is a sequence of writes to (possibly) different writers, which are
+// deferred until flush is called.
+type output []segment
+
+type segment struct {
+	dst io.Writer
+	buf []byte
+}
+
+type segmentWriter struct {
+	out *output
+	dst io.Writer
+}
+
+func (w segmentWriter) Write(p []byte) (int, error) {
+	*w.out = append(*w.out, segment{dst: w.dst, buf: append([]byte(nil), p...)})
+	return len(p), nil
+}
+
+// to returns a writer whose writes are deferred until flush is called, at
+// which point they are written to dst.
+func (o *output) to(dst io.Writer) io.Writer { return segmentWriter{out: o, dst: dst} }
+
+func (o *output) flush() {
+	for _, s := range *o {
+		_, _ = s.dst.Write(s.buf)
+	}
+	*o = nil
+}
+
+// valueAt returns the value of the given column of rec, or an empty string if
+// rec has no such column.
+func valueAt(rec []string, col int) string {
+	if col >= 0 && col < len(rec) {
+		return rec[col]
+	}
+	return ""
+}
+
+func contains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
 		}
-		return r, skip, stop
 	}
+	return false
 }
 
 func escape(str string) string {
@@ -0,0 +1,98 @@
+package csm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ardnew/csm/log"
+	"github.com/ardnew/csm/suite/compare"
+	"github.com/ardnew/csm/suite/dialect"
+	"github.com/ardnew/csm/suite/record"
+)
+
+// Diff compares each test case file of c (the old suite) with the file of the
+// same name in other (the new suite). Test cases are paired by their inputs
+// (or by row number, if opts.JoinRows), and each pair's outputs are compared
+// within the tolerances given by opts.Tolerances. Added, removed, and changed
+// test cases are printed, followed by a summary of each file.
+func (c *CSM) Diff(other *CSM, opts Options) error {
+	log.Msg(log.Info, "diff", "%q -> %q", c.arcPath, other.arcPath)
+
+	old, err := Members(c.csvPath)
+	if nil != err {
+		return err
+	}
+	new, err := Members(other.csvPath)
+	if nil != err {
+		return err
+	}
+	member := append([]string{}, old...)
+	for _, m := range new {
+		if !contains(member, m) {
+			member = append(member, m)
+		}
+	}
+	sortMembers(member)
+
+	for _, m := range member {
+		if !opts.Selected(m) {
+			continue
+		}
+		a, aerr := readRecords(filepath.Join(c.csvPath, m), c.dialect)
+		b, berr := readRecords(filepath.Join(other.csvPath, m), other.dialect)
+		switch {
+		case os.IsNotExist(aerr) && nil == berr:
+			log.Msg(log.Info, "diff", "%s: added file (%d records)", m, len(b.Rows))
+			continue
+		case os.IsNotExist(berr) && nil == aerr:
+			log.Msg(log.Info, "diff", "%s: removed file (%d records)", m, len(a.Rows))
+			continue
+		case nil != aerr:
+			return aerr
+		case nil != berr:
+			return berr
+		}
+
+		res := compare.Diff(a, b, opts.Tolerances, opts.JoinRows)
+		for _, n := range res.Missing {
+			log.Msg(log.Warn, "diff", "%s: removed column: %q", m, n)
+		}
+		for _, n := range res.Extra {
+			log.Msg(log.Warn, "diff", "%s: added column: %q", m, n)
+		}
+		if len(res.Added)+len(res.Removed)+len(res.Changed) > 0 {
+			fmt.Fprintln(os.Stdout, "==", m)
+		}
+		for _, d := range res.Removed {
+			fmt.Fprintf(os.Stdout, "  - row %d: %s\n", d.Row, strings.Join(d.Key, ","))
+		}
+		for _, d := range res.Added {
+			fmt.Fprintf(os.Stdout, "  + row %d: %s\n", d.Other, strings.Join(d.Key, ","))
+		}
+		for _, d := range res.Changed {
+			fmt.Fprintf(os.Stdout, "  ~ row %d -> %d: %s\n", d.Row, d.Other, strings.Join(d.Key, ","))
+			for _, x := range d.Changes {
+				fmt.Fprintf(os.Stdout, "      %s\n", x)
+			}
+		}
+		log.Msg(log.Info, "diff", "%s: %d added, %d removed, %d changed, %d unchanged",
+			m, len(res.Added), len(res.Removed), len(res.Changed), res.Unchanged)
+	}
+	return nil
+}
+
+// readRecords reads every record of the test case file at path.
+func readRecords(path string, d dialect.Options) (compare.Records, error) {
+	it, err := record.Open(path, d)
+	if nil != err {
+		return compare.Records{}, err
+	}
+	defer it.Close()
+	rec := compare.Records{Header: it.Header(), Def: it.Def()}
+	for it.Next() {
+		rec.Rows = append(rec.Rows, it.Record().Values)
+	}
+	return rec, it.Err()
+}
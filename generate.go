@@ -0,0 +1,109 @@
+package csm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ardnew/csm/log"
+	"github.com/ardnew/csm/suite/dialect"
+	"github.com/ardnew/csm/suite/grid"
+	"github.com/ardnew/csm/suite/record"
+)
+
+// Generate replaces the test cases of each selected file with the Cartesian
+// product of the axes in opts.Grid, using the header row of c as a template.
+// If opts.Strength is positive, only enough test cases to cover every
+// combination of values of every opts.Strength axes are generated instead
+// (see grid.Covering).
+// Each generated test case has the value of each axis defined in that file and
+// is empty in all other columns (including the expected outputs), which may be
+// filled in by opts.Assigns. Files not selected retain only their header row.
+// The generated suite replaces the content of c for all subsequent operations.
+func (c *CSM) Generate(opts Options) error {
+	gridPath := filepath.Join(c.xtcPath, GridBase, CsvBase)
+	log.Msg(log.Info, "generate", "%q -> %q", c.csvPath, gridPath)
+	if err := os.RemoveAll(gridPath); nil != err {
+		return err
+	}
+	if err := os.MkdirAll(gridPath, os.ModePerm); nil != err {
+		return err
+	}
+
+	member, err := c.members(opts)
+	if nil != err {
+		return err
+	}
+	used := map[string]bool{}
+	for _, m := range member {
+		gen := grid.Product
+		if opts.Strength > 0 {
+			gen = grid.Covering(opts.Strength)
+		}
+		if !opts.Selected(m) {
+			gen = nil
+		}
+		n, unknown, err := generateMember(
+			filepath.Join(c.csvPath, m), filepath.Join(gridPath, m), c.dialect, opts, gen)
+		if nil != err {
+			return fmt.Errorf("%s: %w", m, err)
+		}
+		if nil != gen {
+			for _, a := range opts.Grid {
+				if !contains(unknown, a.Name) {
+					used[a.Name] = true
+				}
+			}
+			log.Msg(log.Info, "generate", "%s: %d test cases", m, n)
+		}
+	}
+	for _, a := range opts.Grid {
+		if !used[a.Name] {
+			return fmt.Errorf("grid axis is not an input of any test case file: %q", a.Name)
+		}
+	}
+
+	c.csvPath = gridPath
+	return nil
+}
+
+// generateMember writes the header row of the test case file at src to dst,
+// followed by each test case produced by gen from the axes of opts.Grid that
+// are defined in src. If gen is nil, only the header row is written.
+func generateMember(src, dst string, given dialect.Options, opts Options,
+	gen func([][]string, func([]int) error) error) (count int, unknown []string, err error) {
+
+	it, err := record.Open(src, given)
+	if nil != err {
+		return 0, nil, err
+	}
+	header, def, d := it.Header(), it.Def(), it.Dialect()
+	it.Close()
+	def.AddAliases(opts.Aliases)
+
+	col, val, unknown, err := opts.Grid.Columns(def)
+	if nil != err {
+		return 0, nil, err
+	}
+	if nil != gen && len(col) == 0 {
+		log.Msg(log.Warn, "generate", "no grid axes defined: %s", filepath.Base(src))
+		gen = nil
+	}
+	if nil == gen {
+		gen = func([][]string, func([]int) error) error { return nil }
+	} else if n := grid.Size(val); n < 0 {
+		return 0, nil, fmt.Errorf("too many test cases")
+	}
+
+	f, err := os.Create(dst)
+	if nil != err {
+		return 0, nil, err
+	}
+	defer func() {
+		if cerr := f.Close(); nil == err {
+			err = cerr
+		}
+	}()
+	count, err = grid.Write(d.NewWriter(f), header, col, val, gen)
+	return count, unknown, err
+}
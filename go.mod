@@ -1,12 +1,20 @@
 module github.com/ardnew/csm
 
-go 1.15
+go 1.20
 
 require (
 	github.com/cespare/xxhash/v2 v2.1.1
+	github.com/mholt/archiver/v3 v3.3.1
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.0 // indirect
+	github.com/dsnet/compress v0.0.1 // indirect
 	github.com/golang/snappy v0.0.2 // indirect
 	github.com/klauspost/compress v1.11.0 // indirect
 	github.com/klauspost/pgzip v1.2.5 // indirect
-	github.com/mholt/archiver/v3 v3.3.1
+	github.com/nwaples/rardecode v1.1.0 // indirect
+	github.com/pierrec/lz4/v3 v3.3.2 // indirect
 	github.com/ulikunitz/xz v0.5.8 // indirect
+	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8 // indirect
 )
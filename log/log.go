@@ -17,20 +17,29 @@ var Output io.Writer = os.Stdout
 func Raw(format string, args ...interface{}) { fmt.Fprintf(Output, format, args...) }
 
 func Msg(level int, prompt string, format string, args ...interface{}) {
+	Fmsg(Writer(level), level, prompt, format, args...)
+}
+
+// Writer returns the writer to which Msg writes messages of the given level.
+func Writer(level int) io.Writer {
+	if level == Error {
+		return os.Stderr
+	}
+	return Output
+}
+
+// Fmsg formats a message like Msg but writes it to w regardless of level.
+func Fmsg(w io.Writer, level int, prompt string, format string, args ...interface{}) {
 	var prefix string
-	var output io.Writer
 	switch level {
 	case Info:
-		output = Output
 		prefix = "[ ]"
 	case Warn:
-		output = Output
 		prefix = "[*]"
 	case Error:
-		output = os.Stderr
 		prefix = "[!]"
 	}
-	fmt.Fprintf(output, fmt.Sprintf("%s %10s: %s\n", prefix, prompt, format), args...)
+	fmt.Fprintf(w, fmt.Sprintf("%s %10s: %s\n", prefix, prompt, format), args...)
 }
 
 // Digits returns the number of digits in the decimal (base 10) representation
@@ -0,0 +1,132 @@
+package csm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ardnew/csm/log"
+	"github.com/ardnew/csm/suite/dialect"
+	"github.com/ardnew/csm/suite/grid"
+	"github.com/ardnew/csm/suite/record"
+)
+
+// defaultStrength is the coverage strength used by Reduce if not given.
+const defaultStrength = 2
+
+// Reduce replaces the test cases of each selected file with a subset of them,
+// in their original order, that contains every combination of values of every
+// opts.Strength columns named by opts.ReduceCols (see field.Select) that is
+// found in the original test cases. A selected file defining none of those
+// columns retains all of its test cases, but each of opts.ReduceCols must name
+// a column of at least one selected file. The reduced suite replaces the
+// content of c for all subsequent operations.
+func (c *CSM) Reduce(opts Options) error {
+	t := opts.Strength
+	if t == 0 {
+		t = defaultStrength
+	}
+	reducePath := filepath.Join(c.xtcPath, ReduceBase, CsvBase)
+	log.Msg(log.Info, "reduce", "%q -> %q (%d-way)", c.csvPath, reducePath, t)
+	if err := os.RemoveAll(reducePath); nil != err {
+		return err
+	}
+	if err := os.MkdirAll(reducePath, os.ModePerm); nil != err {
+		return err
+	}
+
+	member, err := c.members(opts)
+	if nil != err {
+		return err
+	}
+	used := map[string]bool{}
+	for _, m := range member {
+		if !opts.Selected(m) {
+			if err := copyHeader(filepath.Join(c.csvPath, m),
+				filepath.Join(reducePath, m), c.dialect); nil != err {
+				return err
+			}
+			continue
+		}
+		n, k, unknown, err := reduceMember(
+			filepath.Join(c.csvPath, m), filepath.Join(reducePath, m), c.dialect, opts, t)
+		if nil != err {
+			return fmt.Errorf("%s: %w", m, err)
+		}
+		for _, r := range opts.ReduceCols {
+			if !contains(unknown, r) {
+				used[r] = true
+			}
+		}
+		log.Msg(log.Info, "reduce", "%s: retained %d of %d records", m, k, n)
+	}
+	for _, r := range opts.ReduceCols {
+		if !used[r] {
+			return fmt.Errorf("reduce column is not defined in any test case file: %q", r)
+		}
+	}
+
+	c.csvPath = reducePath
+	return nil
+}
+
+// reduceMember writes the header row of the test case file at src to dst,
+// followed by the subset of its test cases chosen by grid.Reduce, or all of
+// them if none of the columns of opts.ReduceCols are defined in src.
+func reduceMember(src, dst string, d dialect.Options, opts Options, t int) (count, kept int, unknown []string, err error) {
+	it, err := record.Open(src, d)
+	if nil != err {
+		return 0, 0, nil, err
+	}
+	defer it.Close()
+	def := it.Def()
+	def.AddAliases(opts.Aliases)
+	col, unknown := def.Select(opts.ReduceCols)
+	for _, u := range unknown {
+		log.Msg(log.Warn, "reduce", "ignoring unknown field: %s: %q", filepath.Base(src), u)
+	}
+	if len(col) == 0 {
+		log.Msg(log.Warn, "reduce", "no columns to cover, retaining all records: %s",
+			filepath.Base(src))
+	}
+
+	var all, key [][]string
+	for it.Next() {
+		rec := it.Record().Values
+		all = append(all, rec)
+		key = append(key, def.Values(col, rec))
+	}
+	if err := it.Err(); nil != err {
+		return 0, 0, nil, err
+	}
+	keep := make([]int, len(all))
+	for i := range keep {
+		keep[i] = i
+	}
+	if len(col) > 0 {
+		if keep, err = grid.Reduce(key, t); nil != err {
+			return 0, 0, nil, err
+		}
+	}
+
+	f, err := os.Create(dst)
+	if nil != err {
+		return 0, 0, nil, err
+	}
+	defer func() {
+		if cerr := f.Close(); nil == err {
+			err = cerr
+		}
+	}()
+	w := it.Dialect().NewWriter(f)
+	if err := w.Write(it.Header()); nil != err {
+		return 0, 0, nil, err
+	}
+	for _, r := range keep {
+		if err := w.Write(all[r]); nil != err {
+			return 0, 0, nil, err
+		}
+	}
+	w.Flush()
+	return len(all), len(keep), unknown, w.Error()
+}
@@ -0,0 +1,139 @@
+package assign
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ardnew/csm/suite/field"
+)
+
+type Assignment struct {
+	valid bool
+	field string
+	op    AssignOp
+	args  string
+}
+
+type Assignments []Assignment
+
+func (a Assignment) String() string {
+	return fmt.Sprintf("{ %q %s %q }", a.field, a.op, a.args)
+}
+
+func (a *Assignment) SetValid(valid bool) { a.valid = valid }
+func (a Assignment) Valid() bool          { return a.valid }
+func (a Assignment) Field() string        { return a.field }
+func (a Assignment) Args() string         { return a.args }
+
+// Apply modifies the value of the assigned field in the given record, whose
+// columns are defined by def. A constant assigned to an enumerated field may be
+// given as either its coded value or its label. Numeric results retain the
+// precision of the field's value, or of the argument if greater (when adding).
+// Empty values are not modified by numeric operations.
+func (a Assignment) Apply(def *field.FieldDef, rec []string) error {
+	col, ok := def.ColForCsv(a.field)
+	if !ok || col >= len(rec) {
+		return fmt.Errorf("cannot assign unknown field: %q", a.field)
+	}
+
+	if a.op == opSet {
+		rec[col] = a.args
+		if e, ok := def.Enum(a.field); ok {
+			if code, ok := e.Code(a.args); ok {
+				rec[col] = code
+			}
+		}
+		return nil
+	}
+
+	val := strings.TrimSpace(rec[col])
+	if val == "" {
+		return nil // nothing to modify
+	}
+	v, ve := strconv.ParseFloat(val, 64)
+	if nil != ve {
+		return fmt.Errorf("cannot assign non-numeric value: %s: %q", a.String(), val)
+	}
+	x, xe := strconv.ParseFloat(a.args, 64)
+	if nil != xe {
+		return fmt.Errorf("cannot assign non-numeric argument: %s", a.String())
+	}
+
+	prec := precision(val)
+	switch a.op {
+	case opAdd:
+		v += x
+		if p := precision(a.args); p > prec {
+			prec = p
+		}
+	case opMul:
+		v *= x
+	default:
+		return fmt.Errorf("invalid assignment: %s", a.String())
+	}
+	rec[col] = strconv.FormatFloat(v, 'f', prec, 64)
+	return nil
+}
+
+// precision returns the number of digits following the decimal point.
+func precision(s string) int {
+	s = strings.TrimSpace(s)
+	if n := strings.IndexByte(s, '.'); n >= 0 {
+		return len(s) - n - 1
+	}
+	return 0
+}
+
+func (a Assignments) String() string {
+	as := []string{}
+	for _, s := range a {
+		as = append(as, s.String())
+	}
+	return strings.Join(as, ",")
+}
+
+func (a *Assignments) Set(s string) error {
+	if field, args, op := splitAssign(s); opError != op {
+		*a = append(*a, Assignment{field: field, op: op, args: args})
+		return nil
+	}
+	return fmt.Errorf("unrecognized assignment: %q", s)
+}
+
+type AssignOp int
+
+const (
+	opError AssignOp = iota
+	opSet            // =
+	opAdd            // +=
+	opMul            // *=
+	opCount
+)
+
+func (o AssignOp) String() string {
+	switch o {
+	case opSet:
+		return "="
+	case opAdd:
+		return "+="
+	case opMul:
+		return "*="
+	}
+	return ""
+}
+
+func splitAssign(s string) (field, args string, op AssignOp) {
+	// check the compound operators first, since each contains "="
+	for _, o := range []AssignOp{opAdd, opMul, opSet} {
+		tok := o.String()
+		if n := strings.Index(s, tok); n > 0 {
+			field = strings.TrimSpace(s[:n])
+			args = strings.TrimSpace(s[n+len(tok):])
+			if field != "" && (o == opSet || args != "") {
+				return field, args, o
+			}
+		}
+	}
+	return "", "", opError
+}
@@ -0,0 +1,171 @@
+package atomic
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrAborted is returned when creating or committing a file after Abort.
+var ErrAborted = errors.New("aborted")
+
+var (
+	mu      sync.Mutex
+	pending = map[*File]bool{}
+	temp    = map[string]bool{} // see Track
+	aborted bool
+)
+
+// File is a temporary file, in the same directory as the file it replaces,
+// that is renamed into place by Commit, or removed by Abort. Thus, a failure or
+// interruption while writing never leaves a partially written file in place of
+// the original.
+type File struct {
+	*os.File
+	path string
+	done bool
+}
+
+// Create creates a temporary file that replaces the file at path once it is
+// committed. The temporary file is hidden (its name begins with '.'), and has
+// the same extension as path.
+func Create(path string) (*File, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if aborted {
+		return nil, ErrAborted
+	}
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	f, err := os.CreateTemp(dir, "."+strings.TrimSuffix(base, ext)+".tmp-*"+ext)
+	if nil != err {
+		return nil, err
+	}
+	// CreateTemp restricts access to the owner
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); nil == err {
+		mode = info.Mode().Perm()
+	}
+	if err := f.Chmod(mode); nil != err {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	t := &File{File: f, path: path}
+	pending[t] = true
+	return t, nil
+}
+
+// Path returns the path of the file replaced by f.
+func (f *File) Path() string { return f.path }
+
+// Commit closes f and renames it to the path of the file it replaces. If an
+// error occurs, f is removed instead.
+func (f *File) Commit() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if f.done {
+		return ErrAborted
+	}
+	f.done = true
+	delete(pending, f)
+	err := errors.Join(f.Sync(), f.Close())
+	if nil == err {
+		err = os.Rename(f.Name(), f.path)
+	}
+	if nil != err {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Abort closes and removes f, unless it has already been committed or aborted.
+// It is safe to defer Abort immediately after Create.
+func (f *File) Abort() {
+	mu.Lock()
+	defer mu.Unlock()
+	f.abort()
+}
+
+func (f *File) abort() {
+	if !f.done {
+		f.done = true
+		delete(pending, f)
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+// WriteFile calls write with the path of a temporary file, which replaces the
+// file at path if write returns nil, or is removed otherwise. The temporary
+// file exists (and is empty) when write is called.
+func WriteFile(path string, write func(tmp string) error) error {
+	f, err := Create(path)
+	if nil != err {
+		return err
+	}
+	defer f.Abort()
+	if err := write(f.Name()); nil != err {
+		return err
+	}
+	return f.Commit()
+}
+
+// Track registers the temporary file or directory at path to be removed by
+// AbortAll, until it is removed by Remove or no longer tracked by Untrack.
+func Track(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	temp[path] = true
+}
+
+// Untrack causes the file or directory at path, as registered by Track, to no
+// longer be removed by AbortAll (e.g., after it has been renamed).
+func Untrack(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(temp, path)
+}
+
+// Remove removes the file or directory at path, as registered by Track, and
+// any children it contains. It returns nil if path does not exist.
+func Remove(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(temp, path)
+	return os.RemoveAll(path)
+}
+
+// CreateTemp is like os.CreateTemp, but the file is also registered by Track.
+func CreateTemp(dir, pattern string) (*os.File, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if aborted {
+		return nil, ErrAborted
+	}
+	f, err := os.CreateTemp(dir, pattern)
+	if nil != err {
+		return nil, err
+	}
+	temp[f.Name()] = true
+	return f, nil
+}
+
+// AbortAll aborts every file not yet committed, and removes every temporary
+// file and directory registered by Track. It causes all subsequent calls to
+// Create, CreateTemp, and Commit to fail. It is intended to be called when the
+// program is interrupted.
+func AbortAll() {
+	mu.Lock()
+	defer mu.Unlock()
+	aborted = true
+	for f := range pending {
+		f.abort()
+	}
+	for path := range temp {
+		os.RemoveAll(path)
+	}
+	temp = map[string]bool{}
+}
@@ -0,0 +1,318 @@
+package compare
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ardnew/csm/suite/field"
+)
+
+// Tolerance is the maximum difference allowed between two numeric values for
+// them to be considered equal. Abs is an absolute difference, and Rel is a
+// fraction of the magnitude of the expected value. Values are equal if they
+// are within either tolerance.
+type Tolerance struct {
+	Abs float64
+	Rel float64
+}
+
+func (t Tolerance) String() string {
+	if t.Rel > 0 {
+		return strconv.FormatFloat(t.Rel*100, 'g', -1, 64) + "%"
+	}
+	return strconv.FormatFloat(t.Abs, 'g', -1, 64)
+}
+
+// Equal reports whether the given values are equal within tolerance, and their
+// difference (got - want) if both are numeric. Non-numeric values are equal
+// only if they are identical (ignoring surrounding whitespace).
+func (t Tolerance) Equal(want, got string) (equal bool, delta float64, numeric bool) {
+	want, got = strings.TrimSpace(want), strings.TrimSpace(got)
+	w, we := strconv.ParseFloat(want, 64)
+	g, ge := strconv.ParseFloat(got, 64)
+	if nil != we || nil != ge {
+		return want == got, 0, false
+	}
+	delta = g - w
+	d := math.Abs(delta)
+	return d <= t.Abs || d <= t.Rel*math.Abs(w), delta, true
+}
+
+// Tolerances maps field names (or aliases) to the tolerance of their values.
+// The name "*" defines the default tolerance. It implements flag.Value, given
+// as "name=tolerance" pairs, where tolerance is either an absolute difference
+// or a percentage of the expected value (e.g., "VR=0.05", "*=0.1%").
+type Tolerances map[string]Tolerance
+
+func (t Tolerances) String() string {
+	ts := make([]string, 0, len(t))
+	for k, v := range t {
+		ts = append(ts, k+"="+v.String())
+	}
+	sort.Strings(ts)
+	return strings.Join(ts, ",")
+}
+
+func (t *Tolerances) Set(s string) error {
+	if *t == nil {
+		*t = Tolerances{}
+	}
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		n := strings.Index(p, "=")
+		if n < 1 {
+			return fmt.Errorf("unrecognized tolerance: %q", p)
+		}
+		name, val := strings.TrimSpace(p[:n]), strings.TrimSpace(p[n+1:])
+		var tol Tolerance
+		var err error
+		if strings.HasSuffix(val, "%") {
+			tol.Rel, err = strconv.ParseFloat(strings.TrimSuffix(val, "%"), 64)
+			tol.Rel /= 100
+		} else {
+			tol.Abs, err = strconv.ParseFloat(val, 64)
+		}
+		if nil != err || tol.Abs < 0 || tol.Rel < 0 {
+			return fmt.Errorf("unrecognized tolerance: %q", p)
+		}
+		(*t)[name] = tol
+	}
+	return nil
+}
+
+// For returns the tolerance of the column with the given header name, whose
+// fields are defined by def. A tolerance given for an output without either
+// output prefix applies to both its display and extended-precision values.
+// A tolerance given for the exact header name takes precedence over one given
+// for its name without prefix or an alias (in sorted order, if several), which
+// takes precedence over the default.
+func (t Tolerances) For(def *field.FieldDef, name string) Tolerance {
+	if v, ok := t[name]; ok {
+		return v
+	}
+	base := strings.TrimPrefix(strings.TrimPrefix(name, def.ExtPrefix), def.OutPrefix)
+	key := make([]string, 0, len(t))
+	for k := range t {
+		key = append(key, k)
+	}
+	sort.Strings(key)
+	for _, k := range key {
+		if k != "*" && (def.Resolve(k) == name || strings.EqualFold(k, base)) {
+			return t[k]
+		}
+	}
+	return t["*"]
+}
+
+// Change is a single output value that differs between two test cases.
+type Change struct {
+	Column  string  // header name
+	Want    string  // expected (or old) value
+	Got     string  // actual (or new) value
+	Delta   float64 // Got - Want, if Numeric
+	Numeric bool
+}
+
+func (c Change) String() string {
+	if c.Numeric {
+		// show the difference with the precision of the more precise value
+		prec := precision(c.Want)
+		if p := precision(c.Got); p > prec {
+			prec = p
+		}
+		d := strconv.FormatFloat(c.Delta, 'f', prec, 64)
+		if c.Delta >= 0 {
+			d = "+" + d
+		}
+		return fmt.Sprintf("%s: %s -> %s (%s)", c.Column, c.Want, c.Got, d)
+	}
+	return fmt.Sprintf("%s: %q -> %q", c.Column, c.Want, c.Got)
+}
+
+// precision returns the number of digits following the decimal point.
+func precision(s string) int {
+	s = strings.TrimSpace(s)
+	if n := strings.IndexByte(s, '.'); n >= 0 {
+		return len(s) - n - 1
+	}
+	return 0
+}
+
+// roundTo returns the given value rounded to prec decimals, or unmodified if it
+// is not numeric. Ties are rounded away from zero, so that a display value is
+// accepted for the same extended-precision values as by FieldDef.Mismatches.
+// The value is scaled by a small relative error first, so that a tie is found
+// despite binary floating-point noise (e.g., 0.145 is slightly below a tie).
+func roundTo(s string, prec int) string {
+	f, err := strconv.ParseFloat(s, 64)
+	if nil != err {
+		return s
+	}
+	p := math.Pow10(prec)
+	return strconv.FormatFloat(math.Round(f*p*(1+1e-12))/p, 'f', prec, 64)
+}
+
+// Case is a test case found in one or both of the compared files.
+type Case struct {
+	Key     []string // value of each input column
+	Row     int      // row number in the first file (0 if added)
+	Other   int      // row number in the second file (0 if removed)
+	Changes []Change
+}
+
+// Result is the outcome of comparing two test case files.
+type Result struct {
+	Added     []Case // cases found only in the second file
+	Removed   []Case // cases found only in the first file
+	Changed   []Case // cases with at least one changed output
+	Unchanged int    // number of cases with no changed outputs
+	Columns   []string
+	Missing   []string // columns of the first file not in the second
+	Extra     []string // columns of the second file not in the first
+}
+
+// Records is the header and records of a test case file.
+type Records struct {
+	Header []string
+	Def    *field.FieldDef
+	Rows   [][]string
+}
+
+// Diff compares the outputs of each test case in a with those of the test case
+// in b with identical inputs. Test cases with identical inputs within the same
+// file are paired in order. If byRow is true, test cases are instead paired by
+// their row number.
+func Diff(a, b Records, tol Tolerances, byRow bool) *Result {
+	bcol := map[string]int{}
+	for _, c := range b.Def.Columns() {
+		bcol[c.Name] = c.Col
+	}
+	return join(a, b, tol, byRow, func(c field.Column) (int, bool, bool) {
+		j, ok := bcol[c.Name]
+		return j, false, ok
+	})
+}
+
+// Verify compares the expected outputs of each test case in suite with the
+// computed outputs of the test case in results with identical inputs (or row
+// number, if byRow is true). Computed outputs are found by header name, or by
+// name without output prefix, in which case the same computed value is compared
+// with both the display and extended-precision expected values. It is compared
+// with the display value only once rounded to the same number of decimals.
+//
+// In the returned Result, Removed are test cases without results, Added are
+// results without test cases, Changed are test cases that failed, and
+// Unchanged is the number of test cases that passed.
+func Verify(suite, results Records, tol Tolerances, byRow bool) *Result {
+	rcol := map[string]int{}
+	for i, name := range results.Header {
+		rcol[strings.TrimSpace(name)] = i
+	}
+	return join(suite, results, tol, byRow, func(c field.Column) (int, bool, bool) {
+		if j, ok := rcol[c.Name]; ok {
+			return j, false, true
+		}
+		base := strings.TrimPrefix(c.Name, suite.Def.ExtPrefix)
+		base = strings.TrimPrefix(base, suite.Def.OutPrefix)
+		j, ok := rcol[base]
+		return j, c.Kind == field.KindOutput, ok
+	})
+}
+
+// join pairs the test cases of a and b, comparing the outputs of each pair.
+// The column of b corresponding to each column of a is given by match, which
+// also reports whether the value of b is rounded to the precision of the value
+// of a before comparing them.
+func join(a, b Records, tol Tolerances, byRow bool, match func(field.Column) (col int, round, ok bool)) *Result {
+	res := &Result{}
+
+	var inA, inB, outA, outB []int
+	var rounded []bool
+	seen := map[int]bool{}
+	for _, c := range a.Def.Columns() {
+		j, round, ok := match(c)
+		if !ok {
+			res.Missing = append(res.Missing, c.Name)
+			continue
+		}
+		seen[j] = true
+		if c.Kind == field.KindInput {
+			inA, inB = append(inA, c.Col), append(inB, j)
+		} else {
+			outA, outB = append(outA, c.Col), append(outB, j)
+			rounded = append(rounded, round)
+			res.Columns = append(res.Columns, c.Name)
+		}
+	}
+	for i, name := range b.Header {
+		if !seen[i] {
+			res.Extra = append(res.Extra, name)
+		}
+	}
+
+	values := func(r []string, col []int) []string {
+		v := make([]string, len(col))
+		for i, c := range col {
+			if c < len(r) {
+				v[i] = strings.TrimSpace(r[c])
+			}
+		}
+		return v
+	}
+
+	// index the cases of b by key, retaining the order of duplicate keys
+	pending := map[string][]int{}
+	key := func(r []string, row int, col []int) string {
+		if byRow {
+			return strconv.Itoa(row)
+		}
+		return strings.Join(values(r, col), "\x1f")
+	}
+	for i, r := range b.Rows {
+		k := key(r, i+1, inB)
+		pending[k] = append(pending[k], i)
+	}
+	matched := make([]bool, len(b.Rows))
+
+	for i, r := range a.Rows {
+		k := key(r, i+1, inA)
+		q := pending[k]
+		if len(q) == 0 {
+			res.Removed = append(res.Removed, Case{Key: values(r, inA), Row: i + 1})
+			continue
+		}
+		j := q[0]
+		pending[k] = q[1:]
+		matched[j] = true
+
+		c := Case{Key: values(r, inA), Row: i + 1, Other: j + 1}
+		av, bv := values(r, outA), values(b.Rows[j], outB)
+		for n, name := range res.Columns {
+			if rounded[n] {
+				bv[n] = roundTo(bv[n], precision(av[n]))
+			}
+			eq, delta, numeric := tol.For(a.Def, name).Equal(av[n], bv[n])
+			if !eq {
+				c.Changes = append(c.Changes, Change{
+					Column: name, Want: av[n], Got: bv[n], Delta: delta, Numeric: numeric,
+				})
+			}
+		}
+		if len(c.Changes) > 0 {
+			res.Changed = append(res.Changed, c)
+		} else {
+			res.Unchanged += 1
+		}
+	}
+	for j, r := range b.Rows {
+		if !matched[j] {
+			res.Added = append(res.Added, Case{Key: values(r, inB), Other: j + 1})
+		}
+	}
+	return res
+}
@@ -0,0 +1,84 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/ardnew/csm/suite/field"
+)
+
+func records(header []string, rows ...[]string) Records {
+	return Records{
+		Header: header,
+		Def:    field.NewDef(header, field.OutPrefix, field.ExtPrefix),
+		Rows:   rows,
+	}
+}
+
+func TestRoundTo(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		prec int
+		want string
+	}{
+		{"5100.5", 0, "5101"},
+		{"5101.5", 0, "5102"},
+		{"5100.49", 0, "5100"},
+		{"-5100.5", 0, "-5101"},
+		{"0.145", 2, "0.15"},
+		{"1.005", 2, "1.01"},
+		{"142.26", 1, "142.3"},
+		{"142.24", 1, "142.2"},
+		{"12", 2, "12.00"},
+		{"WET", 0, "WET"},
+	} {
+		if got := roundTo(tc.in, tc.prec); got != tc.want {
+			t.Errorf("roundTo(%q, %d) = %q, want %q", tc.in, tc.prec, got, tc.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	suite := records(
+		[]string{"MDS", "RCR", "[outext]TAKEOFF_GROUND_ROLL", "[out]TAKEOFF_GROUND_ROLL"},
+		[]string{"0", "1", "5100.5", "5101"},
+		[]string{"0", "2", "5200.25", "5200"},
+		[]string{"1", "1", "4800.0", "4800"},
+	)
+	results := records(
+		[]string{"MDS", "RCR", "TAKEOFF_GROUND_ROLL"},
+		[]string{"0", "1", "5100.5"},
+		[]string{"0", "2", "5200.25"},
+		[]string{"1", "1", "4801.5"},
+	)
+	res := Verify(suite, results, Tolerances{}, false)
+	if len(res.Missing) != 0 || len(res.Extra) != 0 {
+		t.Fatalf("missing %v, extra %v", res.Missing, res.Extra)
+	}
+	if res.Unchanged != 2 || len(res.Changed) != 1 {
+		t.Fatalf("%d passed, %d failed, want 2 passed, 1 failed", res.Unchanged, len(res.Changed))
+	}
+	c := res.Changed[0]
+	if c.Row != 3 || len(c.Changes) != 2 {
+		t.Fatalf("row %d failed with %v, want row 3 with 2 changes", c.Row, c.Changes)
+	}
+	if x := c.Changes[1]; x.Column != "[out]TAKEOFF_GROUND_ROLL" || x.Got != "4802" {
+		t.Errorf("%s, want [out]TAKEOFF_GROUND_ROLL: 4800 -> 4802", x)
+	}
+}
+
+func TestTolerancesFor(t *testing.T) {
+	def := field.NewDef([]string{"MDS", "[outext]VR", "[out]VR"}, field.OutPrefix, field.ExtPrefix)
+	var tol Tolerances
+	if err := tol.Set("*=0.1%,VR=0.5,[out]VR=1"); nil != err {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]Tolerance{
+		"[out]VR":    {Abs: 1},
+		"[outext]VR": {Abs: 0.5},
+		"MDS":        {Rel: 0.001},
+	} {
+		if got := tol.For(def, name); got != want {
+			t.Errorf("For(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
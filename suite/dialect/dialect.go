@@ -0,0 +1,271 @@
+package dialect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// BOM is the UTF-8 encoded byte order mark.
+const BOM = "\xef\xbb\xbf"
+
+// peekSize is the number of bytes examined to detect a dialect.
+const peekSize = 4096
+
+// delimiters are the field delimiters recognized by Detect, in order of
+// preference.
+const delimiters = ",;\t|"
+
+// comment is the comment character recognized by Detect.
+const comment = '#'
+
+// Dialect describes how the records of a CSV file are formatted, so that new
+// or modified records may be written in the same format as the original.
+type Dialect struct {
+	BOM        bool     // file begins with a UTF-8 byte order mark
+	CRLF       bool     // records end with "\r\n" instead of "\n"
+	QuoteAll   bool     // every field is quoted, not only those that require it
+	Comma      rune     // field delimiter
+	Comment    rune     // lines beginning with Comment are ignored, if not 0
+	LazyQuotes bool     // quotes may appear in unquoted fields
+	Encoding   Encoding // character encoding
+}
+
+// Default is the dialect written by encoding/csv.
+var Default = Dialect{Comma: ',', Encoding: UTF8}
+
+// Options are the properties of a dialect that are given instead of detected.
+// Zero-valued Comma, Comment, and Encoding are detected by Detect.
+type Options struct {
+	Comma      rune
+	Comment    rune
+	LazyQuotes bool
+	Encoding   Encoding
+}
+
+// Options returns the properties of d that are given to Detect, so that the
+// same dialect is detected in any data written by a Writer in dialect d.
+func (d Dialect) Options() Options {
+	return Options{
+		Comma:      d.Comma,
+		Comment:    d.Comment,
+		LazyQuotes: d.LazyQuotes,
+		Encoding:   d.Encoding,
+	}
+}
+
+// Detect returns the dialect of the CSV data read from r, which is determined
+// from its first line that is not a comment, except for the properties given
+// by opts. If opts.Comment is 0, lines beginning with '#' are comments if any
+// such line is found near the beginning of r (within peekSize bytes). The
+// returned reader reads the same data as r, except for any byte order mark,
+// and decoded as UTF-8, and should be used instead of r.
+func Detect(r io.Reader, opts Options) (Dialect, io.Reader, error) {
+	d := Dialect{
+		Comma:      opts.Comma,
+		Comment:    opts.Comment,
+		LazyQuotes: opts.LazyQuotes,
+		Encoding:   opts.Encoding,
+	}
+	br := bufio.NewReaderSize(r, peekSize)
+	buf, err := br.Peek(peekSize)
+	if nil != err && err != io.EOF && err != bufio.ErrBufferFull {
+		return d, br, err
+	}
+	if bytes.HasPrefix(buf, []byte(BOM)) {
+		d.BOM = true
+		buf = buf[len(BOM):]
+		_, _ = br.Discard(len(BOM))
+	}
+	if d.Encoding == "" {
+		d.Encoding = UTF8
+		if !d.BOM && !validUTF8(buf, err == bufio.ErrBufferFull) {
+			d.Encoding = Windows1252
+		}
+	}
+
+	if d.Comment == 0 && hasComment(buf) {
+		d.Comment = comment
+	}
+
+	var line []byte
+	for rest := buf; len(rest) > 0; {
+		line = rest
+		if n := bytes.IndexByte(rest, '\n'); n >= 0 {
+			line, rest = rest[:n+1], rest[n+1:]
+		} else {
+			rest = nil
+		}
+		if t := bytes.TrimSpace(line); len(t) > 0 &&
+			(d.Comment == 0 || !bytes.HasPrefix(t, []byte(string(d.Comment)))) {
+			break
+		}
+	}
+	if bytes.HasSuffix(line, []byte{'\n'}) {
+		line = line[:len(line)-1]
+		d.CRLF = bytes.HasSuffix(line, []byte{'\r'})
+	}
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	if d.Comma == 0 {
+		d.Comma = detectComma(line)
+	}
+	if len(line) > 0 {
+		d.QuoteAll = true
+		for _, f := range bytes.Split(line, []byte(string(d.Comma))) {
+			// a quoted field containing delimiters is split into several, but only
+			// the first begins and only the last ends with a quote.
+			if len(f) == 0 || (f[0] != '"' && f[len(f)-1] != '"') {
+				d.QuoteAll = false
+				break
+			}
+		}
+	}
+	if d.Encoding == Windows1252 {
+		return d, &decoder{r: br}, nil
+	}
+	return d, br, nil
+}
+
+// hasComment reports whether a line of the given data, other than one within
+// a quoted field, begins with the comment character.
+func hasComment(buf []byte) bool {
+	quoted := false
+	for rest := buf; len(rest) > 0; {
+		line := rest
+		if n := bytes.IndexByte(rest, '\n'); n >= 0 {
+			line, rest = rest[:n+1], rest[n+1:]
+		} else {
+			rest = nil
+		}
+		if !quoted && len(line) > 0 && rune(line[0]) == comment {
+			return true
+		}
+		// a line with an odd number of quotes begins or ends a quoted field
+		if bytes.Count(line, []byte{'"'})%2 == 1 {
+			quoted = !quoted
+		}
+	}
+	return false
+}
+
+// detectComma returns the delimiter found most often outside of quotes in the
+// given line, or ',' if none are found.
+func detectComma(line []byte) rune {
+	count := map[byte]int{}
+	quoted := false
+	for _, b := range line {
+		if b == '"' {
+			quoted = !quoted
+		} else if !quoted && strings.IndexByte(delimiters, b) >= 0 {
+			count[b] += 1
+		}
+	}
+	comma, most := byte(','), 0
+	for i := 0; i < len(delimiters); i++ {
+		if n := count[delimiters[i]]; n > most {
+			comma, most = delimiters[i], n
+		}
+	}
+	return rune(comma)
+}
+
+// NewReader returns a csv.Reader of records in dialect d read from r, which
+// must be a reader returned by Detect (or otherwise UTF-8 encoded).
+func (d Dialect) NewReader(r io.Reader) *csv.Reader {
+	c := csv.NewReader(r)
+	if d.Comma != 0 {
+		c.Comma = d.Comma
+	}
+	c.Comment = d.Comment
+	c.LazyQuotes = d.LazyQuotes
+	return c
+}
+
+// Writer writes CSV records in a given dialect. Like csv.Writer, its output is
+// buffered, and Flush must be called to ensure all records are written.
+type Writer struct {
+	d     Dialect
+	w     *bufio.Writer
+	start bool // nothing has been written yet
+	err   error
+}
+
+// NewWriter returns a new Writer writing records in dialect d to w.
+func (d Dialect) NewWriter(w io.Writer) *Writer {
+	if d.Comma == 0 {
+		d.Comma = ','
+	}
+	if d.Encoding == Windows1252 {
+		w = &encoder{w: w}
+	}
+	return &Writer{d: d, w: bufio.NewWriter(w), start: true}
+}
+
+// Write writes a single CSV record, followed by the dialect's line ending.
+func (w *Writer) Write(rec []string) error {
+	var b strings.Builder
+	for i, f := range rec {
+		if i > 0 {
+			b.WriteRune(w.d.Comma)
+		}
+		if !w.d.QuoteAll && !w.needsQuotes(f) {
+			b.WriteString(f)
+			continue
+		}
+		b.WriteByte('"')
+		b.WriteString(strings.ReplaceAll(f, `"`, `""`))
+		b.WriteByte('"')
+	}
+	if w.d.CRLF {
+		b.WriteString("\r\n")
+	} else {
+		b.WriteByte('\n')
+	}
+	return w.WriteRaw([]byte(b.String()))
+}
+
+// WriteRaw writes the given UTF-8 encoded bytes, which must contain complete
+// CSV records (including line endings) or comments, without modification other
+// than encoding.
+func (w *Writer) WriteRaw(p []byte) error {
+	if nil != w.err {
+		return w.err
+	}
+	if w.start {
+		w.start = false
+		if w.d.BOM {
+			_, w.err = w.w.WriteString(BOM)
+		}
+	}
+	if nil == w.err {
+		_, w.err = w.w.Write(p)
+	}
+	return w.err
+}
+
+// Flush writes any buffered data to the underlying io.Writer. To check if an
+// error occurred during Flush, call Error.
+func (w *Writer) Flush() {
+	if nil == w.err {
+		w.err = w.w.Flush()
+	}
+}
+
+// Error reports any error that has occurred during a previous Write or Flush.
+func (w *Writer) Error() error { return w.err }
+
+// needsQuotes reports whether the given field must be quoted, using the same
+// rules as encoding/csv.
+func (w *Writer) needsQuotes(f string) bool {
+	if f == "" {
+		return false
+	}
+	if f == `\.` || strings.ContainsRune(f, w.d.Comma) || strings.ContainsAny(f, "\"\r\n") {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(f)
+	return r == ' ' || r == '\t' || (w.d.Comment != 0 && r == w.d.Comment)
+}
@@ -0,0 +1,155 @@
+package dialect
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+var roundTrip = []struct {
+	name string
+	in   string
+	want Dialect
+}{
+	{"plain", "A,B,C\n1,2,3\n4,,6\n",
+		Dialect{Comma: ',', Encoding: UTF8}},
+	{"bom crlf", BOM + "A,B,C\r\n1,2,3\r\n",
+		Dialect{BOM: true, CRLF: true, Comma: ',', Encoding: UTF8}},
+	{"quote all", "\"A\",\"B\"\n\"1\",\"x,y\"\n",
+		Dialect{QuoteAll: true, Comma: ',', Encoding: UTF8}},
+	{"quoted newline", "A,B\n\"1\n2\",\"say \"\"hi\"\"\"\n",
+		Dialect{Comma: ',', Encoding: UTF8}},
+	{"semicolon", "A;B;C\n1,5;2;3\n",
+		Dialect{Comma: ';', Encoding: UTF8}},
+	{"tab", "A\tB\n1\t2\n",
+		Dialect{Comma: '\t', Encoding: UTF8}},
+	{"windows-1252", "NAME,CODE\ncaf\xe9,\x80\n",
+		Dialect{Comma: ',', Encoding: Windows1252}},
+}
+
+func TestDetect(t *testing.T) {
+	for _, tc := range roundTrip {
+		d, _, err := Detect(strings.NewReader(tc.in), Options{})
+		if nil != err {
+			t.Fatalf("%s: Detect(): %v", tc.name, err)
+		}
+		if d != tc.want {
+			t.Errorf("%s: Detect() = %+v, want %+v", tc.name, d, tc.want)
+		}
+	}
+}
+
+func TestDetectOptions(t *testing.T) {
+	opts := Options{Comma: '|', Comment: '#', LazyQuotes: true, Encoding: UTF8}
+	d, _, err := Detect(strings.NewReader("# A,B\nA|B\n"), opts)
+	if nil != err {
+		t.Fatalf("Detect(): %v", err)
+	}
+	if d.Options() != opts {
+		t.Errorf("Detect().Options() = %+v, want %+v", d.Options(), opts)
+	}
+}
+
+func TestDetectComment(t *testing.T) {
+	d, _, err := Detect(strings.NewReader("# a;b;c;d\r\n\nA,B\n"), Options{Comment: '#'})
+	if nil != err {
+		t.Fatalf("Detect(): %v", err)
+	}
+	if d.Comma != ',' || d.CRLF {
+		t.Errorf("Detect() = %+v, want dialect of first line not a comment", d)
+	}
+	for _, tc := range []struct {
+		in   string
+		opts Options
+		want rune
+	}{
+		{"# suite\nA,B\n0,1\n", Options{}, '#'},
+		{"A,B\n0,1\n# note\n1,2\n", Options{}, '#'},
+		{"A,B\n0,1\n", Options{}, 0},
+		{"A,B\n0,\"two\n# lines\"\n", Options{}, 0},
+		{"A,B\n 0,#1\n", Options{}, 0},
+		{"A,B\n# note\n;1,2\n", Options{Comment: ';'}, ';'},
+	} {
+		d, _, err := Detect(strings.NewReader(tc.in), tc.opts)
+		if nil != err {
+			t.Fatalf("Detect(%q): %v", tc.in, err)
+		}
+		if d.Comment != tc.want {
+			t.Errorf("Detect(%q) comment = %q, want %q", tc.in, d.Comment, tc.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range roundTrip {
+		d, r, err := Detect(strings.NewReader(tc.in), Options{})
+		if nil != err {
+			t.Fatalf("%s: Detect(): %v", tc.name, err)
+		}
+		rec, err := d.NewReader(r).ReadAll()
+		if nil != err {
+			t.Fatalf("%s: ReadAll(): %v", tc.name, err)
+		}
+		var b bytes.Buffer
+		w := d.NewWriter(&b)
+		for _, r := range rec {
+			if err := w.Write(r); nil != err {
+				t.Fatalf("%s: Write(): %v", tc.name, err)
+			}
+		}
+		w.Flush()
+		if err := w.Error(); nil != err {
+			t.Fatalf("%s: Flush(): %v", tc.name, err)
+		}
+		if b.String() != tc.in {
+			t.Errorf("%s: round trip = %q, want %q", tc.name, b.String(), tc.in)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	in := "NAME,CODE\ncaf\xe9,\x80\n"
+	d, r, err := Detect(strings.NewReader(in), Options{})
+	if nil != err {
+		t.Fatalf("Detect(): %v", err)
+	}
+	text, err := io.ReadAll(r)
+	if nil != err {
+		t.Fatalf("ReadAll(): %v", err)
+	}
+	if want := "NAME,CODE\ncafé,€\n"; string(text) != want {
+		t.Errorf("decoded %q, want %q", text, want)
+	}
+	var b bytes.Buffer
+	w := d.NewWriter(&b)
+	if err := w.WriteRaw(text); nil != err {
+		t.Fatalf("WriteRaw(): %v", err)
+	}
+	w.Flush()
+	if b.String() != in {
+		t.Errorf("encoded %q, want %q", b.String(), in)
+	}
+}
+
+func TestWriteQuoting(t *testing.T) {
+	for _, tc := range []struct {
+		d    Dialect
+		rec  []string
+		want string
+	}{
+		{Default, []string{"a", "b c", ""}, "a,b c,\n"},
+		{Default, []string{" a", "x,y", `q"`}, "\" a\",\"x,y\",\"q\"\"\"\n"},
+		{Dialect{Comma: ';', CRLF: true}, []string{"x,y", "a;b"}, "x,y;\"a;b\"\r\n"},
+		{Dialect{Comma: ',', QuoteAll: true}, []string{"1", ""}, "\"1\",\"\"\n"},
+		{Dialect{Comma: ',', Comment: '#'}, []string{"#1", "2#"}, "\"#1\",2#\n"},
+	} {
+		var b bytes.Buffer
+		w := tc.d.NewWriter(&b)
+		w.Write(tc.rec)
+		w.Flush()
+		if b.String() != tc.want {
+			t.Errorf("%+v: Write(%q) = %q, want %q", tc.d, tc.rec, b.String(), tc.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package dialect
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Encoding identifies the character encoding of a CSV file.
+type Encoding string
+
+const (
+	UTF8        Encoding = "utf-8"
+	Windows1252 Encoding = "windows-1252"
+)
+
+// ParseEncoding returns the encoding with the given (case-insensitive) name or
+// one of its common aliases.
+func ParseEncoding(s string) (Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "utf-8", "utf8":
+		return UTF8, nil
+	case "windows-1252", "cp1252", "1252", "latin1", "iso-8859-1":
+		// ISO-8859-1 differs only in (unprintable) 0x80-0x9F
+		return Windows1252, nil
+	}
+	return "", fmt.Errorf("unsupported encoding: %q", s)
+}
+
+// cp1252 maps bytes 0x80-0x9F of Windows-1252 to Unicode. Bytes undefined by
+// Windows-1252 map to the C1 control code of the same value, as with all other
+// bytes (which are identical to ISO-8859-1), so that every byte round-trips.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decoder reads Windows-1252 encoded text from r as UTF-8.
+type decoder struct {
+	r   *bufio.Reader
+	buf []byte // decoded bytes not yet read
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	for len(d.buf) < len(p) {
+		b, err := d.r.ReadByte()
+		if nil != err {
+			if len(d.buf) > 0 {
+				break
+			}
+			return 0, err
+		}
+		r := rune(b)
+		if b >= 0x80 && b < 0xA0 {
+			r = cp1252[b-0x80]
+		}
+		d.buf = utf8.AppendRune(d.buf, r)
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// encoder writes UTF-8 text to w encoded as Windows-1252. Runes that cannot be
+// encoded are written as '?'.
+type encoder struct {
+	w   io.Writer
+	buf []byte // incomplete UTF-8 sequence at end of previous write
+}
+
+func (e *encoder) Write(p []byte) (int, error) {
+	src := append(e.buf, p...)
+	out := make([]byte, 0, len(src))
+	for len(src) > 0 {
+		if !utf8.FullRune(src) {
+			break
+		}
+		r, n := utf8.DecodeRune(src)
+		src = src[n:]
+		out = append(out, encode1252(r))
+	}
+	e.buf = append([]byte(nil), src...)
+	if _, err := e.w.Write(out); nil != err {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func encode1252(r rune) byte {
+	if r < 0x80 || (r >= 0xA0 && r < 0x100) {
+		return byte(r)
+	}
+	for i, c := range cp1252 {
+		if c == r {
+			return byte(0x80 + i)
+		}
+	}
+	return '?'
+}
+
+// validUTF8 reports whether buf is valid UTF-8, ignoring an incomplete rune at
+// the end of buf if more data follows.
+func validUTF8(buf []byte, more bool) bool {
+	for len(buf) > 0 {
+		r, n := utf8.DecodeRune(buf)
+		if r == utf8.RuneError && n == 1 {
+			return more && !utf8.FullRune(buf)
+		}
+		buf = buf[n:]
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package suite
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error is an error concerning a single record of a test case file. Handlers
+// that stop processing due to an error concerning a particular field may
+// return an Error with Col, Field, and Value, whose location is then set by
+// Suite.Locate.
+type Error struct {
+	Suite  string // test suite (archive or directory) containing File, if known
+	File   string // test case file
+	Row    int    // test case number, starting at 1 (0 for the header row)
+	Line   int    // line number, starting at 1 (0 if unknown)
+	Column int    // byte index within Line, starting at 1 (0 if unknown)
+	Col    int    // index of the offending column, if Field is not empty
+	Field  string // header name of the offending column, if known
+	Value  string // offending value, if Field is not empty
+	Text   string // text of line Line, if known
+	Err    error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	if e.Suite != "" {
+		b.WriteString(e.Suite + ": ")
+	}
+	if e.File != "" {
+		b.WriteString(e.File)
+		if e.Line > 0 {
+			b.WriteString(":" + strconv.Itoa(e.Line))
+			if e.Column > 0 {
+				b.WriteString(":" + strconv.Itoa(e.Column))
+			}
+		}
+		b.WriteString(": ")
+	}
+	if e.Row > 0 {
+		fmt.Fprintf(&b, "row %d: ", e.Row)
+	}
+	if e.Field != "" {
+		fmt.Fprintf(&b, "%s = %q: ", e.Field, e.Value)
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Excerpt returns the offending line, prefixed with its line number, followed
+// by a line with a caret (^) beneath the offending column, if known:
+//
+//	12 | 2,"19"0000,1,140.0,140
+//	   |       ^
+//
+// It returns an empty string if the text of the line is unknown.
+func (e *Error) Excerpt() string {
+	if e.Text == "" {
+		return ""
+	}
+	num := strconv.Itoa(e.Line)
+	text := strings.ReplaceAll(e.Text, "\t", " ")
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %s | %s\n", num, text)
+	if e.Column > 0 && e.Column <= len(text)+1 {
+		pad := utf8.RuneCountInString(text[:e.Column-1])
+		fmt.Fprintf(&b, "  %s | %s^\n", strings.Repeat(" ", len(num)), strings.Repeat(" ", pad))
+	}
+	return b.String()
+}
+
+// Locate sets the location of e to that of the last record read, which is the
+// record being processed when a handler stopped processing. If e.Field is not
+// empty, e is located at the beginning of column e.Col.
+func (s *Suite) Locate(e *Error) {
+	if nil == s.cur {
+		return
+	}
+	e.File, e.Row = s.inPath, s.row
+	e.Line, e.Column = s.cur.FieldPos(0)
+	if e.Field != "" && e.Col >= 0 && e.Col < s.fields {
+		e.Line, e.Column = s.cur.FieldPos(e.Col)
+	}
+	e.Text = lineOf(s.raw, e.Line-s.line)
+}
+
+// fieldAt returns the index of the field of the record read from raw (in which
+// fields are separated by comma) containing the given byte column (from 1) of
+// the given line (from 0), and the text of that field up to the end of its
+// line. Quotes are not removed from the text.
+func fieldAt(raw []byte, line, column int, comma rune) (col int, value string) {
+	delim := []byte(string(comma))
+	start, quoted := 0, false
+	for i, ln, pos := 0, 0, 1; i < len(raw) && (ln < line || pos < column); {
+		switch {
+		case raw[i] == '\n':
+			ln, pos = ln+1, 0
+		case raw[i] == '"':
+			quoted = !quoted
+		case !quoted && bytes.HasPrefix(raw[i:], delim):
+			col, start = col+1, i+len(delim)
+			i, pos = i+len(delim), pos+len(delim)
+			continue
+		}
+		i, pos = i+1, pos+1
+	}
+	text := raw[start:]
+	if i := bytes.IndexByte(text, '\n'); i >= 0 {
+		text = bytes.TrimSuffix(text[:i], []byte{'\r'})
+	}
+	if i := bytes.Index(text, delim); i >= 0 {
+		text = text[:i]
+	}
+	return col, string(text)
+}
+
+// lineOf returns the n'th line (from 0) of raw, without its line ending.
+func lineOf(raw []byte, n int) string {
+	for ; n > 0; n-- {
+		i := bytes.IndexByte(raw, '\n')
+		if i < 0 {
+			return ""
+		}
+		raw = raw[i+1:]
+	}
+	if i := bytes.IndexByte(raw, '\n'); i >= 0 {
+		raw = raw[:i]
+	}
+	return string(bytes.TrimSuffix(raw, []byte{'\r'}))
+}
@@ -0,0 +1,142 @@
+package field
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Aliases maps (case-insensitive) short names to the header names they
+// abbreviate. It implements flag.Value, so that it may be given on the command
+// line either as "alias=name" pairs or as the path to a file containing one
+// such pair per line. Blank lines and lines beginning with '#' are ignored.
+type Aliases map[string]string
+
+func (a Aliases) String() string {
+	as := make([]string, 0, len(a))
+	for k, v := range a {
+		as = append(as, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(as)
+	return strings.Join(as, ",")
+}
+
+func (a *Aliases) Set(s string) error {
+	if *a == nil {
+		*a = Aliases{}
+	}
+	if strings.Contains(s, "=") {
+		return a.add(s)
+	}
+	f, err := os.Open(s)
+	if nil != err {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		t := strings.TrimSpace(sc.Text())
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		if err := a.add(t); nil != err {
+			return fmt.Errorf("%s:%d: %w", s, line, err)
+		}
+	}
+	return sc.Err()
+}
+
+func (a *Aliases) add(s string) error {
+	n := strings.Index(s, "=")
+	if n < 0 {
+		return fmt.Errorf("unrecognized alias: %q", s)
+	}
+	alias, name := strings.TrimSpace(s[:n]), strings.TrimSpace(s[n+1:])
+	if alias == "" || name == "" {
+		return fmt.Errorf("unrecognized alias: %q", s)
+	}
+	(*a)[strings.ToLower(alias)] = name
+	return nil
+}
+
+// builtinAliases returns the aliases derived from the given header names. Each
+// name is reachable by its lowercase name without the output prefixes, and by
+// the initials of its underscore-separated words (e.g., "tgr" for
+// "TAKEOFF_GROUND_ROLL"). Aliases of an output refer to its extended-precision
+// value. Any alias derived from more than one field is considered ambiguous
+// and is not defined. An alias never shadows an actual header name, which is
+// always preferred by Resolve.
+func builtinAliases(def *FieldDef) Aliases {
+	alias := Aliases{}
+	ambiguous := map[string]bool{}
+	derive := func(name, base string) {
+		base = strings.ToLower(base)
+		keys := []string{base}
+		if word := strings.FieldsFunc(base, func(r rune) bool {
+			return r == '_'
+		}); len(word) > 1 {
+			var init strings.Builder
+			for _, w := range word {
+				init.WriteByte(w[0])
+			}
+			keys = append(keys, init.String())
+		}
+		for _, k := range keys {
+			if prev, ok := alias[k]; ok && prev != name {
+				ambiguous[k] = true
+			}
+			alias[k] = name
+		}
+	}
+	for _, f := range def.In {
+		derive(f.csvName, f.csvName)
+	}
+	for _, f := range def.Out {
+		derive(f.csvNameExt, strings.TrimPrefix(f.csvNameExt, def.ExtPrefix))
+	}
+	for k := range ambiguous {
+		delete(alias, k)
+	}
+	return alias
+}
+
+// AddAliases defines each of the given aliases, replacing any existing alias
+// of the same name. It returns the aliases whose header name is not defined.
+func (def *FieldDef) AddAliases(a Aliases) []string {
+	var unknown []string
+	for k, v := range a {
+		if _, ok := def.colForName(v); ok {
+			def.Alias[strings.ToLower(k)] = v
+		} else {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// Resolve returns the header name referred to by the given header name or
+// alias. If name is neither, it is returned unmodified.
+func (def *FieldDef) Resolve(name string) string {
+	if _, ok := def.colForName(name); ok {
+		return name
+	}
+	if real, ok := def.Alias[strings.ToLower(name)]; ok {
+		return real
+	}
+	return name
+}
+
+// AliasesFor returns the sorted aliases referring to the given header name.
+func (def *FieldDef) AliasesFor(name string) []string {
+	var as []string
+	for k, v := range def.Alias {
+		if v == name {
+			as = append(as, k)
+		}
+	}
+	sort.Strings(as)
+	return as
+}
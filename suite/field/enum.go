@@ -0,0 +1,65 @@
+package field
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Enum maps the coded values of an enumerated field to their labels.
+type Enum map[string]string
+
+// Label returns the label of the given coded value.
+func (e Enum) Label(code string) (string, bool) {
+	label, ok := e[strings.TrimSpace(code)]
+	return label, ok
+}
+
+// Code returns the coded value of the given (case-insensitive) label.
+func (e Enum) Code(label string) (string, bool) {
+	label = strings.TrimSpace(label)
+	for code, l := range e {
+		if strings.EqualFold(l, label) {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// Codes returns every coded value in ascending order.
+func (e Enum) Codes() []string {
+	code := make([]string, 0, len(e))
+	for c := range e {
+		code = append(code, c)
+	}
+	sort.Slice(code, func(i, j int) bool {
+		a, ae := strconv.Atoi(code[i])
+		b, be := strconv.Atoi(code[j])
+		if nil == ae && nil == be {
+			return a < b
+		}
+		return code[i] < code[j]
+	})
+	return code
+}
+
+// Enum returns the enumeration of the field with the given header name or
+// alias, if it is a known enumerated field.
+func (def *FieldDef) Enum(name string) (Enum, bool) {
+	name = def.Resolve(name)
+	name = strings.TrimPrefix(name, def.ExtPrefix)
+	name = strings.TrimPrefix(name, def.OutPrefix)
+	e, ok := enumMap[strings.ToUpper(strings.TrimSpace(name))]
+	return e, ok
+}
+
+// Decode returns the given value of the named field followed by its label in
+// parentheses, if the field is enumerated and the value is a known code.
+func (def *FieldDef) Decode(name, value string) string {
+	if e, ok := def.Enum(name); ok {
+		if label, ok := e.Label(value); ok {
+			return value + " (" + label + ")"
+		}
+	}
+	return value
+}
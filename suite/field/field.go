@@ -3,11 +3,20 @@ package field
 import (
 	"fmt"
 	"io"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/ardnew/csm/log"
 )
 
+// Prefixes of the header names of each expected output's display and
+// extended-precision values.
+const (
+	OutPrefix = "[out]"
+	ExtPrefix = "[outext]"
+)
+
 type InField struct {
 	csvCol  int
 	csvName string
@@ -22,6 +31,24 @@ type OutField struct {
 
 type FieldId int
 
+// Kind identifies the role of a column in the header row.
+type Kind byte
+
+const (
+	KindInput    Kind = 'I' // test case input
+	KindOutput   Kind = 'O' // expected output (display precision)
+	KindExtended Kind = 'E' // expected output (extended precision)
+)
+
+func (k Kind) String() string { return string(k) }
+
+// Column describes a single column of the header row.
+type Column struct {
+	Kind Kind
+	Col  int
+	Name string
+}
+
 type Spec struct {
 	Name string
 	Col  int
@@ -33,11 +60,13 @@ type FieldDef struct {
 	OutPrefix string
 	ExtPrefix string
 	Selected  []Spec
+	Alias     Aliases
 }
 
 func NewDef(r []string, outPrefix, extPrefix string) *FieldDef {
 
 	var inCount, outCount int
+	inCount = len(r) // all columns are inputs unless an output prefix is found
 	for i, name := range r {
 		if strings.HasPrefix(name, outPrefix) ||
 			strings.HasPrefix(name, extPrefix) {
@@ -58,8 +87,7 @@ func NewDef(r []string, outPrefix, extPrefix string) *FieldDef {
 		if col < inCount {
 			in[col].csvCol = col
 			in[col].csvName = name
-		} else {
-			outCol := (col - inCount) / 2
+		} else if outCol := (col - inCount) / 2; outCol < outCount {
 			// see comment above regarding output column number parity.
 			if inCount&1 == col&1 {
 				out[outCol].csvColExt = col
@@ -71,13 +99,28 @@ func NewDef(r []string, outPrefix, extPrefix string) *FieldDef {
 		}
 	}
 
-	return &FieldDef{
+	def := &FieldDef{
 		In:        in,
 		Out:       out,
 		OutPrefix: outPrefix,
 		ExtPrefix: extPrefix,
 		Selected:  nil,
 	}
+	def.Alias = builtinAliases(def)
+	return def
+}
+
+// Columns returns a description of every column, in header order.
+func (def *FieldDef) Columns() []Column {
+	col := make([]Column, len(def.In)+2*len(def.Out))
+	for _, f := range def.In {
+		col[f.csvCol] = Column{Kind: KindInput, Col: f.csvCol, Name: f.csvName}
+	}
+	for _, f := range def.Out {
+		col[f.csvCol] = Column{Kind: KindOutput, Col: f.csvCol, Name: f.csvName}
+		col[f.csvColExt] = Column{Kind: KindExtended, Col: f.csvColExt, Name: f.csvNameExt}
+	}
+	return col
 }
 
 func (def *FieldDef) inputID(col int) (int, bool) {
@@ -129,6 +172,10 @@ func (def *FieldDef) MakeSpecs(csvName []string) []Spec {
 }
 
 func (def *FieldDef) ColForCsv(csvName string) (col int, ok bool) {
+	return def.colForName(def.Resolve(csvName))
+}
+
+func (def *FieldDef) colForName(csvName string) (col int, ok bool) {
 	if !strings.HasPrefix(csvName, def.OutPrefix) &&
 		!strings.HasPrefix(csvName, def.ExtPrefix) {
 		for _, f := range def.In {
@@ -160,7 +207,7 @@ func (def *FieldDef) ColsForCsv(csvName []string) []int {
 }
 
 func (def *FieldDef) ValueForCsv(csvName string, record []string) (string, bool) {
-	if col, ok := def.ColForCsv(csvName); ok {
+	if col, ok := def.ColForCsv(csvName); ok && col < len(record) {
 		return record[col], true
 	}
 	return "", false
@@ -183,34 +230,121 @@ func (def *FieldDef) Values(col []Spec, record []string) []string {
 func (def *FieldDef) Log(w io.Writer, name string) {
 	n := log.Digits(len(def.In) + len(def.Out))
 	fmt.Fprintln(w, "==", name)
+	line := func(kind string, col int, name string) {
+		fmt.Fprintf(w, "  %s %0*d %q", kind, n, col, name)
+		if as := def.AliasesFor(name); len(as) > 0 {
+			fmt.Fprintf(w, " (%s)", strings.Join(as, ", "))
+		}
+		fmt.Fprintln(w)
+	}
 	for _, f := range def.In {
-		fmt.Fprintf(w, "  I %0*d %q\n", n, f.csvCol, f.csvName)
+		line("I", f.csvCol, f.csvName)
 	}
 	for _, f := range def.Out {
-		fmt.Fprintf(w, "  E %0*d %q\n", n, f.csvColExt, f.csvNameExt)
-		fmt.Fprintf(w, "  O %0*d %q\n", n, f.csvCol, f.csvName)
+		line("E", f.csvColExt, f.csvNameExt)
+		line("O", f.csvCol, f.csvName)
 	}
 }
 
-func (def *FieldDef) LogRecord(record []string) {
-	var fe string
-	for i, f := range record {
-		if csv, ok := def.Input(i); ok {
-			log.Msg(log.Info, "input", "%d:[%s]->%s", i, csv, f)
-		} else if csv, _, ok := def.Output(i); ok {
-			if i&1 == len(def.In)&1 {
-				fe = f
-			} else {
-				log.Msg(log.Info, "output", "%d:[%s]->%s(%s)", i, strings.TrimPrefix(csv, def.OutPrefix), f, fe)
-			}
-		} else {
-			log.Msg(log.Error, "handle", "invalid field (%d): %s", i, f)
+// LogRecord writes each field of the given record on its own line, inputs
+// first (with the labels of enumerated values), followed by each output with
+// its display and extended-precision values side by side.
+func (def *FieldDef) LogRecord(w io.Writer, name string, row int, record []string) {
+	n := log.Digits(len(def.In) + len(def.Out)*2)
+	value := func(col int) string {
+		if col >= 0 && col < len(record) {
+			return record[col]
+		}
+		return ""
+	}
+	wid := 0
+	for _, f := range def.In {
+		if len(f.csvName) > wid {
+			wid = len(f.csvName)
+		}
+	}
+	for _, f := range def.Out {
+		if m := len(strings.TrimPrefix(f.csvName, def.OutPrefix)); m > wid {
+			wid = m
+		}
+	}
+	fmt.Fprintf(w, "== %s: row %d\n", name, row)
+	for _, f := range def.In {
+		fmt.Fprintf(w, "  I %0*d %-*s = %s\n", n, f.csvCol, wid, f.csvName,
+			def.Decode(f.csvName, value(f.csvCol)))
+	}
+	for _, f := range def.Out {
+		fmt.Fprintf(w, "  O %0*d %-*s = %s (%s)\n", n, f.csvCol, wid,
+			strings.TrimPrefix(f.csvName, def.OutPrefix),
+			value(f.csvCol), value(f.csvColExt))
+	}
+	for i := len(def.In) + len(def.Out)*2; i < len(record); i++ {
+		log.Msg(log.Error, "handle", "invalid field (%d): %s", i, record[i])
+	}
+}
+
+// Mismatch describes an output whose display value does not agree with its
+// extended-precision value rounded to the display value's precision.
+type Mismatch struct {
+	Name    string // header name of the display value
+	NameExt string // header name of the extended-precision value
+	Col     int    // column of the display value
+	ColExt  int    // column of the extended-precision value
+	Value   string // display value
+	Ext     string // extended-precision value
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s (column %d) %q != %s (column %d) %q",
+		m.Name, m.Col, m.Value, m.NameExt, m.ColExt, m.Ext)
+}
+
+// Mismatches returns each output pair of the given record whose display value
+// is not equal to its extended-precision value rounded to the display value's
+// precision (the number of digits following its decimal point).
+func (def *FieldDef) Mismatches(record []string) []Mismatch {
+	var mis []Mismatch
+	for _, f := range def.Out {
+		if f.csvCol >= len(record) || f.csvColExt >= len(record) {
+			continue
+		}
+		val, ext := record[f.csvCol], record[f.csvColExt]
+		if !consistent(val, ext) {
+			mis = append(mis, Mismatch{
+				Name:    f.csvName,
+				NameExt: f.csvNameExt,
+				Col:     f.csvCol,
+				ColExt:  f.csvColExt,
+				Value:   val,
+				Ext:     ext,
+			})
 		}
 	}
+	return mis
+}
+
+func consistent(val, ext string) bool {
+	val, ext = strings.TrimSpace(val), strings.TrimSpace(ext)
+	if val == ext {
+		return true
+	}
+	v, ve := strconv.ParseFloat(val, 64)
+	e, ee := strconv.ParseFloat(ext, 64)
+	if nil != ve || nil != ee {
+		return false // non-numeric values must match exactly
+	}
+	prec := 0
+	if n := strings.IndexByte(val, '.'); n >= 0 {
+		prec = len(val) - n - 1
+	}
+	// the display value is a correctly rounded extended value if and only if
+	// they differ by no more than half of the display value's least significant
+	// digit. allow a small relative error for binary floating-point noise.
+	return math.Abs(v-e) <= 0.5*math.Pow10(-prec)*(1+1e-9)
 }
 
 var (
-	thrustMap = map[string]string{
+	thrustMap = Enum{
 		"0": "NONE",
 		"1": "TRT",
 		"2": "EWO",
@@ -218,7 +352,7 @@ var (
 		"4": "MCL",
 		"5": "SPLIT",
 	}
-	mdsMap = map[string]string{
+	mdsMap = Enum{
 		"0": "RC-135S",
 		"1": "RC-135U",
 		"2": "RC-135V",
@@ -229,16 +363,16 @@ var (
 		"7": "WC-135C",
 		"8": "WC-135W",
 	}
-	brakeMap = map[string]string{
+	brakeMap = Enum{
 		"0": "MKII STEEL",
 		"1": "MKIII STEEL",
 		"2": "CARBON",
 	}
-	climbMap = map[string]string{
+	climbMap = Enum{
 		"0": "MAX",
 		"1": "ACCL",
 	}
-	dpobstMap = map[string]string{
+	dpobstMap = Enum{
 		"0": "SDP",
 		"1": "OBSTACLE",
 		"2": "LROC_CAC",
@@ -246,46 +380,168 @@ var (
 		"4": "LROC_DIR_CLB",
 		"5": "ODP",
 	}
-	flapMap = map[string]string{
+	flapMap = Enum{
 		"0": "0",
 		"1": "20",
 		"2": "30",
 		"3": "40",
 		"4": "50",
 	}
-	hwbenMap = map[string]string{
+	hwbenMap = Enum{
 		"0": "0%",
 		"1": "50%",
 		"2": "100%",
 	}
-	lnpMap = map[string]string{
+	lnpMap = Enum{
 		"0": "NUMERIC",
 		"1": "STATIC",
 		"2": "ROLLING",
 		"3": "STATIC/ROLLING",
 	}
-	lflMap = map[string]string{
+	lflMap = Enum{
 		"0": "2.0G",
 		"1": "2.5G",
 	}
-	rcrMap = map[string]string{
+	rcrMap = Enum{
 		"0": "NUMERIC",
 		"1": "DRY",
 		"2": "WET",
 		"3": "SLUSHY",
 	}
-	scrhtMap = map[string]string{
+	scrhtMap = Enum{
 		"0": "0ft",
 		"1": "16ft",
 		"2": "35ft",
 	}
-	spdbrkMap = map[string]string{
+	spdbrkMap = Enum{
 		"0": "INOP",
 		"1": "NORM",
 		"2": "PART",
 	}
-	modeMap = map[string]string{
+	modeMap = Enum{
 		"0": "NORMAL",
 		"1": "EWO",
 	}
+	enumMap = map[string]Enum{
+		"THRUST": thrustMap,
+		"MDS":    mdsMap,
+		"BRAKE":  brakeMap,
+		"CLIMB":  climbMap,
+		"DPOBST": dpobstMap,
+		"FLAP":   flapMap,
+		"HWBEN":  hwbenMap,
+		"LNP":    lnpMap,
+		"LFL":    lflMap,
+		"RCR":    rcrMap,
+		"SCRHT":  scrhtMap,
+		"SPDBRK": spdbrkMap,
+		"MODE":   modeMap,
+	}
 )
+
+// Project returns the columns retained by the given terms (see Select), in the
+// order they are to be written. If keep is empty, all columns are retained in
+// header order; otherwise, only those selected by keep are retained, in the
+// order given. Any column selected by drop is then removed. Terms that do not
+// refer to any column are returned as unknown.
+//
+// Each output is kept or dropped as a pair of columns, so a term selecting
+// either its extended-precision or its display value selects both.
+func (def *FieldDef) Project(keep, drop []string) (col []int, unknown []string) {
+	var spec []Spec
+	if len(keep) == 0 {
+		for _, c := range def.Columns() {
+			spec = append(spec, Spec{Name: c.Name, Col: c.Col})
+		}
+	} else {
+		spec, unknown = def.Select(keep)
+		spec = def.pairs(spec)
+	}
+	dropSpec, dropUnknown := def.Select(drop)
+	dropSpec = def.pairs(dropSpec)
+	unknown = append(unknown, dropUnknown...)
+
+	seen := map[int]bool{}
+	for _, s := range dropSpec {
+		seen[s.Col] = true
+	}
+	col = make([]int, 0, len(spec))
+	for _, s := range spec {
+		if !seen[s.Col] {
+			col = append(col, s.Col)
+			seen[s.Col] = true
+		}
+	}
+	return col, unknown
+}
+
+// pairs returns the given columns with each output column replaced by both
+// columns of its output pair, extended-precision value first.
+func (def *FieldDef) pairs(spec []Spec) []Spec {
+	all := make([]Spec, 0, len(spec))
+	for _, s := range spec {
+		if id, ok := def.outputID(s.Col); ok {
+			f := def.Out[id]
+			all = append(all,
+				Spec{Name: f.csvNameExt, Col: f.csvColExt},
+				Spec{Name: f.csvName, Col: f.csvCol})
+			continue
+		}
+		all = append(all, s)
+	}
+	return all
+}
+
+// Validate verifies the given header row is well-formed: all inputs precede
+// the outputs, and each output is a pair of adjacent columns with the same
+// name, its extended-precision value followed by its display value.
+func Validate(r []string, outPrefix, extPrefix string) error {
+	out := len(r)
+	for i, name := range r {
+		if strings.HasPrefix(name, outPrefix) || strings.HasPrefix(name, extPrefix) {
+			out = i
+			break
+		}
+	}
+	if (len(r)-out)&1 != 0 {
+		return fmt.Errorf("odd number of output columns: %d", len(r)-out)
+	}
+	for i := out; i < len(r); i += 2 {
+		ext, val := r[i], r[i+1]
+		switch {
+		case !strings.HasPrefix(ext, extPrefix):
+			return fmt.Errorf("column %d: expected %s output: %q", i, extPrefix, ext)
+		case !strings.HasPrefix(val, outPrefix):
+			return fmt.Errorf("column %d: expected %s output: %q", i+1, outPrefix, val)
+		case strings.TrimPrefix(ext, extPrefix) != strings.TrimPrefix(val, outPrefix):
+			return fmt.Errorf("columns %d-%d: mismatched output pair: %q, %q", i, i+1, ext, val)
+		}
+	}
+	return nil
+}
+
+// Compatible returns the column of other corresponding to each column of def.
+// The definitions are compatible if they have the same inputs and outputs,
+// regardless of the order in which they appear.
+func (def *FieldDef) Compatible(other *FieldDef) ([]int, error) {
+	col := map[string]Column{}
+	for _, c := range other.Columns() {
+		col[c.Name] = c
+	}
+	mine := def.Columns()
+	if len(mine) != len(col) {
+		return nil, fmt.Errorf("different number of columns: %d, %d", len(mine), len(col))
+	}
+	idx := make([]int, len(mine))
+	for i, c := range mine {
+		o, ok := col[c.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing column: %q", c.Name)
+		}
+		if o.Kind != c.Kind {
+			return nil, fmt.Errorf("different kind of column: %q (%s, %s)", c.Name, c.Kind, o.Kind)
+		}
+		idx[i] = o.Col
+	}
+	return idx, nil
+}
@@ -0,0 +1,114 @@
+package field
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Matcher reports whether a string matches a search term.
+type Matcher func(string) bool
+
+// IsPattern reports whether the given search term is a regular expression,
+// which is delimited by slashes (e.g., "/^VR?$/"), or a glob containing the
+// wildcards '*' or '?'. Brackets are not special in globs, since they are
+// commonly found in output header names (e.g., "[out]V*").
+func IsPattern(term string) bool {
+	return isRegexp(term) || strings.ContainsAny(term, "*?")
+}
+
+func isRegexp(term string) bool {
+	return len(term) > 1 && strings.HasPrefix(term, "/") && strings.HasSuffix(term, "/")
+}
+
+// NewMatcher returns a Matcher for the given search term. Regular expressions
+// match any substring, globs must match the entire string (ignoring case), and
+// all other terms match any substring (ignoring case).
+func NewMatcher(term string) (Matcher, error) {
+	var expr string
+	switch {
+	case isRegexp(term):
+		expr = term[1 : len(term)-1]
+	case IsPattern(term):
+		var b strings.Builder
+		b.WriteString("(?i)^")
+		for _, c := range term {
+			switch c {
+			case '*':
+				b.WriteString(".*")
+			case '?':
+				b.WriteString(".")
+			default:
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		b.WriteString("$")
+		expr = b.String()
+	default:
+		term = strings.ToLower(term)
+		return func(s string) bool {
+			return strings.Contains(strings.ToLower(s), term)
+		}, nil
+	}
+	re, err := regexp.Compile(expr)
+	if nil != err {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+// Kind selectors recognized by Select, each of which expands to every column
+// of the corresponding Kind.
+const (
+	SelectAll      = "@all"
+	SelectInputs   = "@inputs"
+	SelectOutputs  = "@outputs"
+	SelectExtended = "@extended"
+)
+
+// Select returns the columns referred to by each of the given terms, in the
+// order given. Each term is either a kind selector (e.g., "@inputs"), a header
+// name or alias, or a pattern recognized by IsPattern. Kind selectors and
+// patterns expand to every matching column in header order. Terms that do not
+// refer to any column are returned as unknown.
+func (def *FieldDef) Select(term []string) (spec []Spec, unknown []string) {
+	spec = make([]Spec, 0, len(term))
+	expand := func(t string, ok func(Column) bool) {
+		n := len(spec)
+		for _, c := range def.Columns() {
+			if ok(c) {
+				spec = append(spec, Spec{Name: c.Name, Col: c.Col})
+			}
+		}
+		if n == len(spec) {
+			unknown = append(unknown, t)
+		}
+	}
+	for _, t := range term {
+		switch strings.ToLower(t) {
+		case SelectAll:
+			expand(t, func(Column) bool { return true })
+			continue
+		case SelectInputs:
+			expand(t, func(c Column) bool { return c.Kind == KindInput })
+			continue
+		case SelectOutputs:
+			expand(t, func(c Column) bool { return c.Kind == KindOutput })
+			continue
+		case SelectExtended:
+			expand(t, func(c Column) bool { return c.Kind == KindExtended })
+			continue
+		}
+		if n, ok := def.ColForCsv(t); ok {
+			spec = append(spec, Spec{Name: t, Col: n})
+			continue
+		}
+		if IsPattern(t) {
+			if m, err := NewMatcher(t); nil == err {
+				expand(t, func(c Column) bool { return m(c.Name) })
+				continue
+			}
+		}
+		unknown = append(unknown, t)
+	}
+	return spec, unknown
+}
@@ -224,3 +224,54 @@ func splitFilter(s string) (field, args string, op FilterOp) {
 	}
 	return "", "", opError
 }
+
+// Rows is a set of test case numbers, given as a comma-separated list of
+// numbers and ranges (e.g., "1,4-7,12"). Test cases are numbered from 1 in the
+// order they appear in a file, not counting the header row.
+type Rows []RowRange
+
+type RowRange struct {
+	First int
+	Last  int
+}
+
+func (r Rows) String() string {
+	rs := []string{}
+	for _, n := range r {
+		if n.First == n.Last {
+			rs = append(rs, strconv.Itoa(n.First))
+		} else {
+			rs = append(rs, fmt.Sprintf("%d-%d", n.First, n.Last))
+		}
+	}
+	return strings.Join(rs, ",")
+}
+
+func (r *Rows) Set(s string) error {
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		first, last := t, t
+		if n := strings.Index(t, "-"); n > 0 {
+			first, last = t[:n], t[n+1:]
+		}
+		a, ae := strconv.Atoi(strings.TrimSpace(first))
+		b, be := strconv.Atoi(strings.TrimSpace(last))
+		if nil != ae || nil != be || a < 1 || b < a {
+			return fmt.Errorf("unrecognized row range: %q", t)
+		}
+		*r = append(*r, RowRange{First: a, Last: b})
+	}
+	return nil
+}
+
+func (r Rows) Contains(row int) bool {
+	for _, n := range r {
+		if row >= n.First && row <= n.Last {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package grid
+
+import (
+	"fmt"
+	"sort"
+)
+
+// maxTuples is the maximum number of t-way combinations tracked for coverage.
+const maxTuples = 1 << 24
+
+// coverage tracks which combinations of values of every t axes (t-tuples) are
+// not yet covered by a set of test cases.
+type coverage struct {
+	radix  []int   // number of values of each axis
+	subset [][]int // axes of each t-tuple, in ascending order
+	miss   [][]bool
+	remain int
+}
+
+// newCoverage returns the coverage of t-tuples of axes having the given number
+// of values, with all t-tuples initially covered if covered is true, or all
+// uncovered otherwise.
+func newCoverage(radix []int, t int, covered bool) (*coverage, error) {
+	if t < 1 {
+		return nil, fmt.Errorf("invalid coverage strength: %d", t)
+	}
+	if t > len(radix) {
+		t = len(radix)
+	}
+	c := &coverage{radix: radix}
+	total := 0
+	var walk func(s []int, next int) error
+	walk = func(s []int, next int) error {
+		if len(s) == t {
+			n := 1
+			for _, a := range s {
+				n *= radix[a]
+			}
+			if total += n; total > maxTuples {
+				return fmt.Errorf("too many %d-way combinations", t)
+			}
+			miss := make([]bool, n)
+			for i := range miss {
+				miss[i] = !covered
+			}
+			c.subset = append(c.subset, append([]int(nil), s...))
+			c.miss = append(c.miss, miss)
+			return nil
+		}
+		for a := next; a < len(radix); a++ {
+			if err := walk(append(s, a), a+1); nil != err {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(nil, 0); nil != err {
+		return nil, err
+	}
+	if !covered {
+		c.remain = total
+	}
+	return c, nil
+}
+
+// index returns the index of the t-tuple of subset s found in row idx, or -1
+// if any of its axes are unassigned (negative) in idx.
+func (c *coverage) index(s int, idx []int) int {
+	k := 0
+	for _, a := range c.subset[s] {
+		if idx[a] < 0 {
+			return -1
+		}
+		k = k*c.radix[a] + idx[a]
+	}
+	return k
+}
+
+// gain returns the number of uncovered t-tuples found in row idx.
+func (c *coverage) gain(idx []int) int {
+	n := 0
+	for s := range c.subset {
+		if k := c.index(s, idx); k >= 0 && c.miss[s][k] {
+			n += 1
+		}
+	}
+	return n
+}
+
+// set marks each t-tuple found in row idx as uncovered (if miss is true) or
+// covered (otherwise).
+func (c *coverage) set(idx []int, miss bool) {
+	for s := range c.subset {
+		if k := c.index(s, idx); k >= 0 && c.miss[s][k] != miss {
+			c.miss[s][k] = miss
+			if miss {
+				c.remain += 1
+			} else {
+				c.remain -= 1
+			}
+		}
+	}
+}
+
+// Covering returns a generator, like Product, of test cases in which every
+// combination of values of every t axes occurs at least once (a covering array
+// of strength t). For t = 2, this is commonly known as all-pairs testing. The
+// test cases are constructed greedily, and deterministically, one at a time:
+// each begins with the first uncovered t-tuple, and each of its remaining axes
+// is assigned the value covering the most uncovered t-tuples. If t is not less
+// than the number of axes, the generator is equivalent to Product.
+func Covering(t int) func(val [][]string, fn func(idx []int) error) error {
+	return func(val [][]string, fn func(idx []int) error) error {
+		if t >= len(val) {
+			return Product(val, fn)
+		}
+		if Size(val) == 0 {
+			return nil
+		}
+		radix := make([]int, len(val))
+		for i, v := range val {
+			radix[i] = len(v)
+		}
+		c, err := newCoverage(radix, t, false)
+		if nil != err {
+			return err
+		}
+		idx := make([]int, len(val))
+		first := 0 // no t-tuple of any preceding subset is uncovered
+		for c.remain > 0 {
+			for i := range idx {
+				idx[i] = -1
+			}
+			// seed the test case with the first uncovered t-tuple
+			for ; first < len(c.subset); first++ {
+				if k := indexOf(c.miss[first], true); k >= 0 {
+					s := c.subset[first]
+					for i := len(s) - 1; i >= 0; i-- {
+						idx[s[i]] = k % radix[s[i]]
+						k /= radix[s[i]]
+					}
+					break
+				}
+			}
+			for a := range idx {
+				if idx[a] >= 0 {
+					continue
+				}
+				best, most := 0, -1
+				for v := 0; v < radix[a]; v++ {
+					idx[a] = v
+					if n := c.gain(idx); n > most {
+						best, most = v, n
+					}
+				}
+				idx[a] = best
+			}
+			c.set(idx, false)
+			if err := fn(idx); nil != err {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Reduce returns the (ascending) index of each of a subset of the given rows,
+// in which every combination of values of every t columns found in any row is
+// found at least once. The subset is chosen greedily, by repeatedly selecting
+// the row containing the most combinations not yet found, or the first such
+// row if there are several. The rows must have at least one column.
+func Reduce(rows [][]string, t int) ([]int, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	// assign each distinct value of each column an index
+	cols := len(rows[0])
+	if cols == 0 {
+		return nil, fmt.Errorf("no columns to cover")
+	}
+	code := make([]map[string]int, cols)
+	for i := range code {
+		code[i] = map[string]int{}
+	}
+	idx := make([][]int, len(rows))
+	for r, row := range rows {
+		if len(row) != cols {
+			return nil, fmt.Errorf("row %d: wrong number of columns", r+1)
+		}
+		idx[r] = make([]int, cols)
+		for i, v := range row {
+			k, ok := code[i][v]
+			if !ok {
+				k = len(code[i])
+				code[i][v] = k
+			}
+			idx[r][i] = k
+		}
+	}
+	radix := make([]int, cols)
+	for i := range radix {
+		radix[i] = len(code[i])
+	}
+
+	c, err := newCoverage(radix, t, true)
+	if nil != err {
+		return nil, err
+	}
+	for _, x := range idx {
+		c.set(x, true)
+	}
+	var keep []int
+	used := make([]bool, len(rows))
+	for c.remain > 0 {
+		best, most := -1, 0
+		for r, x := range idx {
+			if used[r] {
+				continue
+			}
+			if n := c.gain(x); n > most {
+				best, most = r, n
+			}
+		}
+		used[best] = true
+		keep = append(keep, best)
+		c.set(idx[best], false)
+	}
+	sort.Ints(keep)
+	return keep, nil
+}
+
+func indexOf(list []bool, b bool) int {
+	for i, v := range list {
+		if v == b {
+			return i
+		}
+	}
+	return -1
+}
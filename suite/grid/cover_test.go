@@ -0,0 +1,127 @@
+package grid
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// axes returns axes with the given number of values each.
+func axes(radix ...int) [][]string {
+	val := make([][]string, len(radix))
+	for i, n := range radix {
+		for v := 0; v < n; v++ {
+			val[i] = append(val[i], strconv.Itoa(v))
+		}
+	}
+	return val
+}
+
+// tuples returns the set of every combination of values of every t columns
+// found in the given rows.
+func tuples(rows [][]string, t int) map[string]bool {
+	set := map[string]bool{}
+	var walk func(row []string, start int, key []string)
+	walk = func(row []string, start int, key []string) {
+		if len(key) == t {
+			set[strings.Join(key, " ")] = true
+			return
+		}
+		for i := start; i < len(row); i++ {
+			walk(row, i+1, append(key, fmt.Sprintf("%d=%s", i, row[i])))
+		}
+	}
+	for _, r := range rows {
+		walk(r, 0, nil)
+	}
+	return set
+}
+
+func generate(t *testing.T, gen func([][]string, func([]int) error) error, val [][]string) [][]string {
+	t.Helper()
+	var rows [][]string
+	err := gen(val, func(idx []int) error {
+		row := make([]string, len(idx))
+		for i, k := range idx {
+			row[i] = val[i][k]
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("generate: %v", err)
+	}
+	return rows
+}
+
+func TestCovering(t *testing.T) {
+	for _, tc := range []struct {
+		radix []int
+		t     int
+		max   int // upper bound on the number of test cases
+	}{
+		{[]int{2, 2, 2}, 2, 6},
+		{[]int{3, 3, 3, 3}, 2, 15},
+		{[]int{4, 3, 2, 2, 5}, 2, 30},
+		{[]int{2, 2, 2, 2, 2}, 3, 20},
+		{[]int{3, 2}, 2, 6}, // equivalent to Product
+	} {
+		val := axes(tc.radix...)
+		all := generate(t, Product, val)
+		rows := generate(t, Covering(tc.t), val)
+		want, got := tuples(all, tc.t), tuples(rows, tc.t)
+		for k := range want {
+			if !got[k] {
+				t.Errorf("%v, t=%d: tuple not covered: %s", tc.radix, tc.t, k)
+			}
+		}
+		if len(rows) > tc.max || len(rows) > len(all) {
+			t.Errorf("%v, t=%d: %d test cases, want at most %d", tc.radix, tc.t, len(rows), tc.max)
+		}
+		// deterministic
+		if again := generate(t, Covering(tc.t), val); fmt.Sprint(again) != fmt.Sprint(rows) {
+			t.Errorf("%v, t=%d: different test cases generated", tc.radix, tc.t)
+		}
+	}
+}
+
+func TestReduce(t *testing.T) {
+	rows := generate(t, Product, axes(3, 3, 2, 2))
+	// duplicates and a row with values not found elsewhere
+	rows = append(rows, rows[0], rows[5], []string{"9", "0", "0", "0"})
+	for _, n := range []int{1, 2, 3} {
+		keep, err := Reduce(rows, n)
+		if nil != err {
+			t.Fatalf("t=%d: Reduce(): %v", n, err)
+		}
+		var sub [][]string
+		for i, k := range keep {
+			if i > 0 && k <= keep[i-1] {
+				t.Fatalf("t=%d: indices not ascending: %v", n, keep)
+			}
+			sub = append(sub, rows[k])
+		}
+		want, got := tuples(rows, n), tuples(sub, n)
+		for k := range want {
+			if !got[k] {
+				t.Errorf("t=%d: tuple not covered: %s", n, k)
+			}
+		}
+		if len(keep) >= len(rows) {
+			t.Errorf("t=%d: retained all %d rows", n, len(rows))
+		}
+	}
+}
+
+func TestReduceColumns(t *testing.T) {
+	if _, err := Reduce([][]string{{"0", "1"}, {"0"}}, 2); nil == err {
+		t.Error("Reduce() of rows of different width succeeded")
+	}
+	if _, err := Reduce([][]string{{}, {}}, 2); nil == err {
+		t.Error("Reduce() of rows without columns succeeded")
+	}
+	if keep, err := Reduce(nil, 2); nil != err || len(keep) != 0 {
+		t.Errorf("Reduce(nil) = %v, %v", keep, err)
+	}
+}
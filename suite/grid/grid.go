@@ -0,0 +1,248 @@
+package grid
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ardnew/csm/suite/dialect"
+	"github.com/ardnew/csm/suite/field"
+)
+
+// Axis is a single input column of a grid and the values it takes.
+type Axis struct {
+	Name  string   // header name or alias of an input column
+	Items []string // values, ranges, and wildcards, as given
+}
+
+// Grid is a list of axes whose Cartesian product defines a set of test cases.
+// It implements flag.Value, so that each axis may be given on the command line
+// as "NAME=item,item,...", where each item is one of:
+//
+//	value          a single value (or label, if NAME is enumerated)
+//	lo:hi[:step]   every value from lo to hi, inclusive, by step (default 1)
+//	*              every coded value of the enumerated field NAME
+//
+// Items given for the same axis more than once are appended to that axis.
+type Grid []Axis
+
+func (g Grid) String() string {
+	gs := make([]string, len(g))
+	for i, a := range g {
+		gs[i] = a.Name + "=" + strings.Join(a.Items, ",")
+	}
+	return strings.Join(gs, " ")
+}
+
+func (g *Grid) Set(s string) error {
+	n := strings.Index(s, "=")
+	if n < 1 {
+		return fmt.Errorf("unrecognized grid axis: %q", s)
+	}
+	name := strings.TrimSpace(s[:n])
+	var items []string
+	for _, it := range strings.Split(s[n+1:], ",") {
+		if it = strings.TrimSpace(it); it == "" {
+			continue
+		}
+		if strings.Contains(it, ":") {
+			if _, err := expand(it); nil != err {
+				return err
+			}
+		}
+		items = append(items, it)
+	}
+	if name == "" || len(items) == 0 {
+		return fmt.Errorf("unrecognized grid axis: %q", s)
+	}
+	for i := range *g {
+		if strings.EqualFold((*g)[i].Name, name) {
+			(*g)[i].Items = append((*g)[i].Items, items...)
+			return nil
+		}
+	}
+	*g = append(*g, Axis{Name: name, Items: items})
+	return nil
+}
+
+// Values returns the distinct values of the axis, in the order given, for the
+// input column defined by def. Enumerated labels are replaced by their coded
+// values.
+func (a Axis) Values(def *field.FieldDef) ([]string, error) {
+	enum, isEnum := def.Enum(a.Name)
+	var val []string
+	seen := map[string]bool{}
+	add := func(v string) {
+		if !seen[v] {
+			seen[v] = true
+			val = append(val, v)
+		}
+	}
+	for _, it := range a.Items {
+		switch {
+		case it == "*":
+			if !isEnum {
+				return nil, fmt.Errorf("cannot expand %q: not an enumerated field: %q", it, a.Name)
+			}
+			for _, c := range enum.Codes() {
+				add(c)
+			}
+		case strings.Contains(it, ":"):
+			r, err := expand(it)
+			if nil != err {
+				return nil, err
+			}
+			for _, v := range r {
+				add(v)
+			}
+		default:
+			if isEnum {
+				if code, ok := enum.Code(it); ok {
+					it = code
+				}
+			}
+			add(it)
+		}
+	}
+	return val, nil
+}
+
+// maxRange is the maximum number of values produced by a single range.
+const maxRange = 1 << 20
+
+// expand returns each value of the range "lo:hi[:step]", formatted with the
+// greatest number of decimal places of lo, hi, and step.
+func expand(s string) ([]string, error) {
+	part := strings.Split(s, ":")
+	if len(part) < 2 || len(part) > 3 {
+		return nil, fmt.Errorf("unrecognized range: %q", s)
+	}
+	if len(part) == 2 {
+		part = append(part, "1")
+	}
+	num := make([]float64, len(part))
+	prec := 0
+	for i, p := range part {
+		p = strings.TrimSpace(p)
+		v, err := strconv.ParseFloat(p, 64)
+		if nil != err {
+			return nil, fmt.Errorf("invalid range: %q: %w", s, err)
+		}
+		num[i] = v
+		if n := strings.IndexByte(p, '.'); n >= 0 && len(p)-n-1 > prec {
+			prec = len(p) - n - 1
+		}
+	}
+	lo, hi, step := num[0], num[1], num[2]
+	if step == 0 || (hi-lo)*step < 0 {
+		return nil, fmt.Errorf("invalid range step: %q", s)
+	}
+	// the number of steps is rounded to tolerate inexact decimal fractions
+	n := math.Floor((hi-lo)/step + 1e-9)
+	if n >= maxRange {
+		return nil, fmt.Errorf("range too large: %q", s)
+	}
+	val := make([]string, 0, int(n)+1)
+	for i := 0; i <= int(n); i++ {
+		val = append(val, strconv.FormatFloat(lo+float64(i)*step, 'f', prec, 64))
+	}
+	return val, nil
+}
+
+// Columns returns the column of each axis defined as an input by def, and the
+// values of each such axis. Axes not defined by def are returned as unknown.
+func (g Grid) Columns(def *field.FieldDef) (col []int, val [][]string, unknown []string, err error) {
+	for _, a := range g {
+		n, ok := def.ColForCsv(a.Name)
+		if ok {
+			_, ok = def.Input(n)
+		}
+		if !ok {
+			unknown = append(unknown, a.Name)
+			continue
+		}
+		if contains(col, n) {
+			return nil, nil, nil, fmt.Errorf("duplicate grid axis: %q", a.Name)
+		}
+		v, err := a.Values(def)
+		if nil != err {
+			return nil, nil, nil, err
+		}
+		col, val = append(col, n), append(val, v)
+	}
+	return col, val, unknown, nil
+}
+
+// Size returns the number of combinations of the given values, or -1 if it
+// overflows an int.
+func Size(val [][]string) int {
+	size := 1
+	for _, v := range val {
+		if len(v) > 0 && size > math.MaxInt32/len(v) {
+			return -1
+		}
+		size *= len(v)
+	}
+	return size
+}
+
+// Product calls fn with the index of each value in every combination of the
+// given values. The last axis varies fastest. The slice passed to fn is reused
+// between calls. Product stops at the first error returned by fn.
+func Product(val [][]string, fn func(idx []int) error) error {
+	if Size(val) == 0 {
+		return nil
+	}
+	idx := make([]int, len(val))
+	for {
+		if err := fn(idx); nil != err {
+			return err
+		}
+		i := len(idx) - 1
+		for ; i >= 0; i-- {
+			if idx[i]++; idx[i] < len(val[i]) {
+				break
+			}
+			idx[i] = 0
+		}
+		if i < 0 {
+			return nil
+		}
+	}
+}
+
+// Write writes the given header row to w, followed by one record for each
+// combination of idx produced by gen. Each record has the value of each axis
+// (at the given column) and is empty elsewhere. It returns the number of
+// records written.
+func Write(w *dialect.Writer, header []string, col []int, val [][]string,
+	gen func(val [][]string, fn func(idx []int) error) error) (int, error) {
+
+	if err := w.Write(header); nil != err {
+		return 0, err
+	}
+	count := 0
+	err := gen(val, func(idx []int) error {
+		rec := make([]string, len(header))
+		for i, c := range col {
+			rec[c] = val[i][idx[i]]
+		}
+		count += 1
+		return w.Write(rec)
+	})
+	if nil != err {
+		return count, err
+	}
+	w.Flush()
+	return count, w.Error()
+}
+
+func contains(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
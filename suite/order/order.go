@@ -0,0 +1,401 @@
+package order
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ardnew/csm/suite/atomic"
+	"github.com/ardnew/csm/suite/dialect"
+	"github.com/ardnew/csm/suite/field"
+)
+
+// DefaultLimit is the approximate number of bytes of records sorted in memory
+// at once by File, beyond which sorted runs are merged from temporary files.
+const DefaultLimit = 64 << 20
+
+// Mode determines how the values of a sort key are compared.
+type Mode int
+
+const (
+	ModeAuto    Mode = iota // enum if enumerated, else numeric if both are numbers, else string
+	ModeNumeric             // numbers before non-numbers, which compare as strings
+	ModeString              // lexical
+	ModeEnum                // order in which codes are defined, unknown codes last
+)
+
+// Key is a single sort key, given as a field name (or alias) optionally
+// followed by any of the modifiers ":asc", ":desc", ":num", ":str", or
+// ":enum" (e.g., "WEIGHT:desc").
+type Key struct {
+	field string
+	desc  bool
+	mode  Mode
+}
+
+// Keys is a list of sort keys, ordered from most to least significant.
+type Keys []Key
+
+func (k Key) String() string {
+	s := k.field
+	switch k.mode {
+	case ModeNumeric:
+		s += ":num"
+	case ModeString:
+		s += ":str"
+	case ModeEnum:
+		s += ":enum"
+	}
+	if k.desc {
+		s += ":desc"
+	}
+	return s
+}
+
+func (k Key) Field() string { return k.field }
+
+func (k Keys) String() string {
+	ks := []string{}
+	for _, s := range k {
+		ks = append(ks, s.String())
+	}
+	return strings.Join(ks, ",")
+}
+
+func (k *Keys) Set(s string) error {
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		part := strings.Split(t, ":")
+		key := Key{field: strings.TrimSpace(part[0])}
+		if key.field == "" {
+			return fmt.Errorf("unrecognized sort key: %q", t)
+		}
+		for _, m := range part[1:] {
+			switch strings.ToLower(strings.TrimSpace(m)) {
+			case "asc":
+				key.desc = false
+			case "desc":
+				key.desc = true
+			case "num":
+				key.mode = ModeNumeric
+			case "str":
+				key.mode = ModeString
+			case "enum":
+				key.mode = ModeEnum
+			default:
+				return fmt.Errorf("unrecognized sort key modifier: %q", t)
+			}
+		}
+		*k = append(*k, key)
+	}
+	return nil
+}
+
+// Compare returns a function comparing two records whose columns are defined
+// by def, according to each of the keys. It returns the keys whose field is not
+// defined, which are otherwise ignored.
+func (k Keys) Compare(def *field.FieldDef) (cmp func(a, b []string) int, unknown []string) {
+	type key struct {
+		Key
+		col  int
+		rank map[string]int // position of each code, if enumerated
+	}
+	var ks []key
+	for _, s := range k {
+		col, ok := def.ColForCsv(s.field)
+		if !ok {
+			unknown = append(unknown, s.field)
+			continue
+		}
+		kk := key{Key: s, col: col}
+		if e, ok := def.Enum(s.field); ok && (s.mode == ModeAuto || s.mode == ModeEnum) {
+			kk.mode = ModeEnum
+			kk.rank = map[string]int{}
+			for i, c := range e.Codes() {
+				kk.rank[c] = i
+			}
+		} else if s.mode == ModeEnum {
+			kk.mode = ModeAuto // not enumerated
+		}
+		ks = append(ks, kk)
+	}
+	return func(a, b []string) int {
+		for _, s := range ks {
+			var x, y string
+			if s.col < len(a) {
+				x = a[s.col]
+			}
+			if s.col < len(b) {
+				y = b[s.col]
+			}
+			n := compare(x, y, s.mode, s.rank)
+			if s.desc {
+				n = -n
+			}
+			if n != 0 {
+				return n
+			}
+		}
+		return 0
+	}, unknown
+}
+
+func compare(x, y string, mode Mode, rank map[string]int) int {
+	x, y = strings.TrimSpace(x), strings.TrimSpace(y)
+	switch mode {
+	case ModeEnum:
+		rx, okx := rank[x]
+		ry, oky := rank[y]
+		switch {
+		case okx && oky:
+			return rx - ry
+		case okx:
+			return -1
+		case oky:
+			return 1
+		}
+		return strings.Compare(x, y)
+	case ModeString:
+		return strings.Compare(x, y)
+	}
+	fx, ex := strconv.ParseFloat(x, 64)
+	fy, ey := strconv.ParseFloat(y, 64)
+	switch {
+	case nil == ex && nil == ey:
+		switch {
+		case fx < fy:
+			return -1
+		case fx > fy:
+			return 1
+		}
+		return 0
+	case nil == ex:
+		return -1
+	case nil == ey:
+		return 1
+	}
+	return strings.Compare(x, y)
+}
+
+// File sorts the records of the CSV file at path in place, retaining the
+// header row (first line). Records that compare equal retain their relative
+// order. At most approximately limit bytes of records are held in memory at
+// once; larger files are sorted in runs, which are written to temporary files
+// and then merged. The sorted file is written in the dialect of the original,
+// which is detected except for the properties given by opts.
+func File(path string, opts dialect.Options, cmp func(a, b []string) int, limit int) (err error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	dir := filepath.Dir(path)
+
+	in, err := os.Open(path)
+	if nil != err {
+		return err
+	}
+	defer in.Close()
+	d, br, err := dialect.Detect(in, opts)
+	if nil != err {
+		return err
+	}
+	r := d.NewReader(br)
+	r.FieldsPerRecord = -1
+	hdr, err := r.Read()
+	if nil != err {
+		if err == io.EOF {
+			return nil // empty file
+		}
+		return err
+	}
+
+	var runs []string
+	defer func() {
+		for _, f := range runs {
+			atomic.Remove(f)
+		}
+	}()
+
+	var chunk [][]string
+	size := 0
+	for {
+		rec, rerr := r.Read()
+		if nil != rerr && rerr != io.EOF {
+			return rerr
+		}
+		if nil != rec {
+			chunk = append(chunk, rec)
+			for _, f := range rec {
+				size += len(f) + 16 // approximate per-field overhead
+			}
+		}
+		if (rerr == io.EOF && len(runs) > 0 && len(chunk) > 0) || size >= limit {
+			sort.SliceStable(chunk, func(i, j int) bool { return cmp(chunk[i], chunk[j]) < 0 })
+			f, err := writeTemp(dir, dialect.Default, nil, chunk)
+			if nil != err {
+				return err
+			}
+			runs = append(runs, f)
+			chunk, size = nil, 0
+		}
+		if rerr == io.EOF {
+			break
+		}
+	}
+	in.Close()
+
+	// everything fit in memory, no merge necessary
+	if len(runs) == 0 {
+		sort.SliceStable(chunk, func(i, j int) bool { return cmp(chunk[i], chunk[j]) < 0 })
+		tmp, err := writeTemp(dir, d, hdr, chunk)
+		if nil != err {
+			return err
+		}
+		return rename(tmp, path)
+	}
+
+	tmp, err := mergeRuns(dir, d, hdr, runs, cmp)
+	if nil != err {
+		return err
+	}
+	return rename(tmp, path)
+}
+
+// rename renames the temporary file tmp, created by writeTemp or mergeRuns, to
+// path, or removes it if an error occurs.
+func rename(tmp, path string) error {
+	if err := os.Rename(tmp, path); nil != err {
+		atomic.Remove(tmp)
+		return err
+	}
+	atomic.Untrack(tmp)
+	return nil
+}
+
+// writeTemp writes the given header row (if not nil) and records to a new
+// temporary file in dialect d, returning its name.
+func writeTemp(dir string, d dialect.Dialect, hdr []string, rec [][]string) (name string, err error) {
+	f, err := atomic.CreateTemp(dir, ".sort-*")
+	if nil != err {
+		return "", err
+	}
+	defer func() {
+		if cerr := f.Close(); nil == err {
+			err = cerr
+		}
+		if nil != err {
+			atomic.Remove(f.Name())
+		}
+	}()
+	w := d.NewWriter(f)
+	if nil != hdr {
+		if err := w.Write(hdr); nil != err {
+			return f.Name(), err
+		}
+	}
+	for _, r := range rec {
+		if err := w.Write(r); nil != err {
+			return f.Name(), err
+		}
+	}
+	w.Flush()
+	return f.Name(), w.Error()
+}
+
+// run is a sorted sequence of records read from a temporary file.
+type run struct {
+	index int // order of run in input, for stability
+	rec   []string
+	r     *csv.Reader
+	f     *os.File
+}
+
+type runHeap struct {
+	run []*run
+	cmp func(a, b []string) int
+}
+
+func (h runHeap) Len() int { return len(h.run) }
+func (h runHeap) Less(i, j int) bool {
+	if n := h.cmp(h.run[i].rec, h.run[j].rec); n != 0 {
+		return n < 0
+	}
+	return h.run[i].index < h.run[j].index
+}
+func (h runHeap) Swap(i, j int)       { h.run[i], h.run[j] = h.run[j], h.run[i] }
+func (h *runHeap) Push(x interface{}) { h.run = append(h.run, x.(*run)) }
+func (h *runHeap) Pop() interface{} {
+	n := len(h.run) - 1
+	x := h.run[n]
+	h.run = h.run[:n]
+	return x
+}
+
+// mergeRuns merges the records of each run, written by writeTemp in the default
+// dialect, into a new temporary file in dialect d, returning its name.
+func mergeRuns(dir string, d dialect.Dialect, hdr []string, runs []string, cmp func(a, b []string) int) (name string, err error) {
+	h := &runHeap{cmp: cmp}
+	defer func() {
+		for _, r := range h.run {
+			r.f.Close()
+		}
+	}()
+	for i, p := range runs {
+		f, err := os.Open(p)
+		if nil != err {
+			return "", err
+		}
+		r := &run{index: i, r: dialect.Default.NewReader(f), f: f}
+		r.r.FieldsPerRecord = -1
+		if r.rec, err = r.r.Read(); nil != err {
+			f.Close()
+			if err == io.EOF {
+				continue
+			}
+			return "", err
+		}
+		h.run = append(h.run, r)
+	}
+	heap.Init(h)
+
+	out, err := atomic.CreateTemp(dir, ".sort-*")
+	if nil != err {
+		return "", err
+	}
+	defer func() {
+		if cerr := out.Close(); nil == err {
+			err = cerr
+		}
+		if nil != err {
+			atomic.Remove(out.Name())
+		}
+	}()
+	w := d.NewWriter(out)
+	if err := w.Write(hdr); nil != err {
+		return out.Name(), err
+	}
+	for h.Len() > 0 {
+		r := h.run[0]
+		if err := w.Write(r.rec); nil != err {
+			return out.Name(), err
+		}
+		if r.rec, err = r.r.Read(); nil != err {
+			if err != io.EOF {
+				return out.Name(), err
+			}
+			r.f.Close()
+			heap.Pop(h)
+			continue
+		}
+		heap.Fix(h, 0)
+	}
+	w.Flush()
+	return out.Name(), w.Error()
+}
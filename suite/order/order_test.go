@@ -0,0 +1,119 @@
+package order
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ardnew/csm/suite/dialect"
+	"github.com/ardnew/csm/suite/field"
+)
+
+const header = "MDS,GROSS_WEIGHT,RCR,[outext]VR,[out]VR"
+
+// suite returns a test case file with n records, whose MDS cycles through 3
+// codes and whose GROSS_WEIGHT decreases, with the given line ending.
+func suite(n int, eol string) string {
+	var b strings.Builder
+	b.WriteString(header + eol)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "%d,%d,%d,%d.5,%d.5%s", i%3, 300000-i*100, i, i, i, eol)
+	}
+	return b.String()
+}
+
+func sortFile(t *testing.T, content, keys string, limit int) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "takeoff.testcase.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); nil != err {
+		t.Fatal(err)
+	}
+	var k Keys
+	if err := k.Set(keys); nil != err {
+		t.Fatalf("Set(%q): %v", keys, err)
+	}
+	hdr := strings.Split(header, ",")
+	cmp, unknown := k.Compare(field.NewDef(hdr, field.OutPrefix, field.ExtPrefix))
+	if len(unknown) > 0 {
+		t.Fatalf("unknown keys: %v", unknown)
+	}
+	if err := File(path, dialect.Options{}, cmp, limit); nil != err {
+		t.Fatalf("File(): %v", err)
+	}
+	ent, err := os.ReadDir(dir)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(ent) != 1 {
+		t.Errorf("found %d files, want temporary files removed", len(ent))
+	}
+	b, err := os.ReadFile(path)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestFile(t *testing.T) {
+	in := suite(50, "\n")
+	want := sortFile(t, in, "MDS,GROSS_WEIGHT", DefaultLimit)
+	lines := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	if lines[0] != header || len(lines) != 51 {
+		t.Fatalf("sorted file has %d lines, header %q", len(lines), lines[0])
+	}
+	if lines[1] != "0,295200,48,48.5,48.5" || lines[50] != "2,299800,2,2.5,2.5" {
+		t.Errorf("sorted file begins %q, ends %q", lines[1], lines[50])
+	}
+	// sorted in runs of a few records each, and then merged
+	for _, limit := range []int{1, 100, 1000} {
+		if got := sortFile(t, in, "MDS,GROSS_WEIGHT", limit); got != want {
+			t.Errorf("limit %d: merged runs differ from sort in memory:\n%s", limit, got)
+		}
+	}
+}
+
+func TestFileStable(t *testing.T) {
+	in := suite(30, "\n")
+	want := sortFile(t, in, "MDS:desc", DefaultLimit)
+	lines := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	// records with equal keys retain their order
+	if lines[1] != "2,299800,2,2.5,2.5" || lines[2] != "2,299500,5,5.5,5.5" {
+		t.Errorf("sorted file begins %q, %q", lines[1], lines[2])
+	}
+	if got := sortFile(t, in, "MDS:desc", 200); got != want {
+		t.Errorf("merged runs differ from sort in memory:\n%s", got)
+	}
+}
+
+func TestFileDialect(t *testing.T) {
+	in := dialect.BOM + suite(20, "\r\n")
+	for _, limit := range []int{DefaultLimit, 100} {
+		got := sortFile(t, in, "GROSS_WEIGHT:num", limit)
+		if !strings.HasPrefix(got, dialect.BOM+header+"\r\n1,298100,19,") {
+			t.Errorf("limit %d: sorted file begins %q", limit, got[:60])
+		}
+		if strings.Count(got, "\r\n") != 21 {
+			t.Errorf("limit %d: line endings not retained", limit)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	for _, tc := range []struct {
+		x, y string
+		mode Mode
+		want int
+	}{
+		{"9", "10", ModeAuto, -1},
+		{"9", "10", ModeString, 1},
+		{"abc", "10", ModeNumeric, 1},
+		{" 2.50", "2.5", ModeAuto, 0},
+	} {
+		if got := compare(tc.x, tc.y, tc.mode, nil); got != tc.want {
+			t.Errorf("compare(%q, %q, %d) = %d, want %d", tc.x, tc.y, tc.mode, got, tc.want)
+		}
+	}
+}
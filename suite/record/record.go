@@ -0,0 +1,203 @@
+package record
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ardnew/csm/suite/dialect"
+	"github.com/ardnew/csm/suite/field"
+)
+
+var (
+	ErrUnknownField = errors.New("unknown field")
+	ErrNotEnum      = errors.New("not an enumerated field")
+	ErrNotOutput    = errors.New("not an output field")
+)
+
+// Record is a single test case read from a test case file.
+type Record struct {
+	Def    *field.FieldDef // field definitions parsed from the header row
+	File   string          // name of the test case file
+	Row    int             // test case number, starting at 1
+	Values []string        // value of each column
+}
+
+// Iterator reads the test cases of a single test case file, one at a time.
+//
+//	it, err := record.Open("takeoff.testcase.csv", dialect.Options{})
+//	if nil != err {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		w, err := it.Record().Float("GROSS_WEIGHT")
+//		...
+//	}
+//	return it.Err()
+type Iterator struct {
+	file   string
+	header []string
+	def    *field.FieldDef
+	dial   dialect.Dialect
+	csv    *csv.Reader
+	closer io.Closer
+	rec    *Record
+	err    error
+}
+
+// New returns an Iterator over the test cases read from r, whose header row is
+// read immediately. The given file name is only used to identify records. The
+// dialect of r is detected except for the properties given by opts.
+func New(r io.Reader, file string, opts dialect.Options) (*Iterator, error) {
+	d, r, err := dialect.Detect(r, opts)
+	if nil != err {
+		return nil, err
+	}
+	c := d.NewReader(r)
+	hdr, err := c.Read()
+	if nil != err {
+		if err == io.EOF {
+			err = fmt.Errorf("%s: missing header row", file)
+		}
+		return nil, err
+	}
+	return &Iterator{
+		file:   file,
+		header: hdr,
+		def:    field.NewDef(hdr, field.OutPrefix, field.ExtPrefix),
+		dial:   d,
+		csv:    c,
+	}, nil
+}
+
+// Open returns an Iterator over the test cases in the file at path, which must
+// be closed when no longer needed. See New.
+func Open(path string, opts dialect.Options) (*Iterator, error) {
+	f, err := os.Open(path)
+	if nil != err {
+		return nil, err
+	}
+	it, err := New(f, path, opts)
+	if nil != err {
+		f.Close()
+		return nil, err
+	}
+	it.closer = f
+	return it, nil
+}
+
+// Header returns the header row.
+func (it *Iterator) Header() []string { return it.header }
+
+// Def returns the field definitions parsed from the header row.
+func (it *Iterator) Def() *field.FieldDef { return it.def }
+
+// Dialect returns the dialect detected in the test case file.
+func (it *Iterator) Dialect() dialect.Dialect { return it.dial }
+
+// Next advances to the next record, which is then available via Record. It
+// returns false when no records remain or an error occurs.
+func (it *Iterator) Next() bool {
+	if nil != it.err {
+		return false
+	}
+	val, err := it.csv.Read()
+	if nil != err {
+		if err != io.EOF {
+			it.err = err
+		}
+		it.rec = nil
+		return false
+	}
+	row := 1
+	if nil != it.rec {
+		row = it.rec.Row + 1
+	}
+	it.rec = &Record{Def: it.def, File: it.file, Row: row, Values: val}
+	return true
+}
+
+// Record returns the current record.
+func (it *Iterator) Record() *Record { return it.rec }
+
+// Err returns the first error encountered, if any, other than io.EOF.
+func (it *Iterator) Err() error { return it.err }
+
+// Close closes the file opened by Open, if any.
+func (it *Iterator) Close() error {
+	if nil != it.closer {
+		return it.closer.Close()
+	}
+	return nil
+}
+
+// String returns the value of the field with the given header name or alias.
+func (r *Record) String(name string) (string, error) {
+	col, ok := r.Def.ColForCsv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownField, name)
+	}
+	if col >= len(r.Values) {
+		return "", fmt.Errorf("%s: row %d: missing value: %q", r.File, r.Row, name)
+	}
+	return r.Values[col], nil
+}
+
+// Float returns the value of the named field as a float64.
+func (r *Record) Float(name string) (float64, error) {
+	v, err := r.String(name)
+	if nil != err {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(v), 64)
+}
+
+// Int returns the value of the named field as an int64.
+func (r *Record) Int(name string) (int64, error) {
+	v, err := r.String(name)
+	if nil != err {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+}
+
+// Enum returns the label of the coded value of the named enumerated field.
+func (r *Record) Enum(name string) (string, error) {
+	e, ok := r.Def.Enum(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrNotEnum, name)
+	}
+	v, err := r.String(name)
+	if nil != err {
+		return "", err
+	}
+	label, ok := e.Label(v)
+	if !ok {
+		return "", fmt.Errorf("%s: row %d: unknown %s code: %q", r.File, r.Row, name, v)
+	}
+	return label, nil
+}
+
+// Output returns the display and extended-precision values of the named
+// output. The name may be given with or without either output prefix, or as an
+// alias of either value.
+func (r *Record) Output(name string) (display, extended string, err error) {
+	base := r.Def.Resolve(name)
+	base = strings.TrimPrefix(base, r.Def.ExtPrefix)
+	base = strings.TrimPrefix(base, r.Def.OutPrefix)
+	if _, ok := r.Def.ColForCsv(r.Def.OutPrefix + base); !ok {
+		return "", "", fmt.Errorf("%w: %q", ErrNotOutput, name)
+	}
+	if display, err = r.String(r.Def.OutPrefix + base); nil != err {
+		return "", "", err
+	}
+	if extended, err = r.String(r.Def.ExtPrefix + base); nil != err {
+		return "", "", err
+	}
+	return display, extended, nil
+}
@@ -1,10 +1,16 @@
 package suite
 
 import (
+	"bytes"
+	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+
+	"github.com/ardnew/csm/suite/atomic"
+	"github.com/ardnew/csm/suite/dialect"
 )
 
 type Suite struct {
@@ -12,46 +18,139 @@ type Suite struct {
 	outPath   string
 	Processed int
 	Filtered  int
+	Rejected  int
+	Dialect   dialect.Dialect // dialect detected in (and written to) output
+
+	header []string // header row, once read
+
+	// location of the last record read, see Locate
+	cur    *csv.Reader
+	raw    []byte // text of the record, without preceding comments
+	line   int    // line number of the first line of raw
+	row    int    // test case number
+	fields int    // number of fields
 }
 
-type RecordHandler func([]string) (rec []string, skip, stop bool)
+// RecordHandler is called with each record read, which it may modify, and
+// returns the record to be written unless skip is true. Processing stops after
+// the record if stop is true. If err is not nil, processing stops with err,
+// and the record is not written.
+type RecordHandler func([]string) (rec []string, skip, stop bool, err error)
 
-func New(in, out string, define, handle RecordHandler) (*Suite, error) {
+// RejectHandler is called with each data row that cannot be processed, given
+// its line number, its raw text (without line ending), and the reason it was
+// rejected. If it returns an error, processing stops with that error.
+type RejectHandler func(line int, raw []byte, reason error) error
 
-	s := Suite{inPath: in, outPath: out}
+// New reads test cases from the file at path in and writes the records retained
+// by the given handlers to a new file at path out, replacing any existing file
+// only if successful. The dialect of the file is detected except for the
+// properties given by opts.
+// If out is empty, retained records are discarded. If reject is nil, processing
+// stops at the first malformed row; otherwise, see Process.
+func New(in, out string, opts dialect.Options, define, handle RecordHandler, reject RejectHandler) (*Suite, error) {
 
 	o := io.Discard
-	if s.outPath != "" {
-		err := os.RemoveAll(s.outPath)
-		if nil != err {
+	var f *atomic.File
+	if out != "" {
+		var err error
+		if f, err = atomic.Create(out); nil != err {
 			return nil, err
 		}
-		f, err := os.Create(s.outPath)
-		if nil != err {
-			return nil, err
-		}
-		defer func() { _, _ = f.Sync(), f.Close() }()
+		defer f.Abort() // unless committed
 		// be sure to substitute the output writer from io.Discard (a bit bucket,
 		// or null device) to our physical output file.
 		o = f
 	}
 
-	i, err := os.Open(s.inPath)
+	i, err := os.Open(in)
 	if nil != err {
 		return nil, err
 	}
 	defer i.Close()
 
-	if err := s.filter(i, o, define, handle); nil != err {
-		return nil, fmt.Errorf("%s->%s: %s", s.inPath, s.outPath, err.Error())
+	s, err := Process(context.Background(), i, o, opts, define, handle, reject)
+	if nil != err {
+		var e *Error
+		if errors.As(err, &e) {
+			e.File = in
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s->%s: %w", in, out, err)
+	}
+	if nil != f {
+		if err := f.Commit(); nil != err {
+			return nil, err
+		}
+	}
+	s.inPath, s.outPath = in, out
+	return s, nil
+}
+
+// Process reads test cases from r and writes the records retained by the given
+// handlers to w. The header row is given to define, and each subsequent row is
+// given to handle. Processing ends when r is exhausted, a handler requests to
+// stop or returns an error, or ctx is done. If w is nil, retained records are
+// discarded.
+//
+// If reject is not nil, each data row that cannot be parsed, or whose number of
+// fields differs from the header row, is given to reject instead of handle, and
+// processing continues. Otherwise, processing stops with an error. Rejected rows
+// are counted in the test case number (Error.Row) of each subsequent row.
+//
+// Records are written in the dialect detected in r, except for the properties
+// given by opts. Each record retained without modification by its handler is
+// written exactly as it was read.
+//
+// Errors concerning a particular record, such as a malformed row or an error
+// returned by a handler, are returned as an *Error. The returned Suite contains
+// the number of records processed and retained, even if an error occurred.
+func Process(ctx context.Context, r io.Reader, w io.Writer, opts dialect.Options, define, handle RecordHandler, reject RejectHandler) (*Suite, error) {
+	if nil == w {
+		w = io.Discard
 	}
-	return &s, nil
+	var s Suite
+	return &s, s.filter(ctx, r, w, opts, define, handle, reject)
 }
 
-func (s *Suite) filter(r io.Reader, w io.Writer, d, h RecordHandler) (err error) {
+func (s *Suite) filter(ctx context.Context, r io.Reader, w io.Writer, opts dialect.Options, d, h RecordHandler, x RejectHandler) (err error) {
 
-	ci := csv.NewReader(r)
-	co := csv.NewWriter(w)
+	s.Dialect, r, err = dialect.Detect(r, opts)
+	if nil != err {
+		return err
+	}
+	in := &recorder{r: r}
+	ci := s.Dialect.NewReader(in)
+	co := s.Dialect.NewWriter(w)
+	if nil != x {
+		ci.FieldsPerRecord = -1 // validated against the header row instead
+	}
+	width := 0
+	// rejectRow gives the row read from raw bytes to x, retaining the blank and
+	// comment lines preceding it.
+	rejectRow := func(line int, raw []byte, reason error) error {
+		c := comments(raw, s.Dialect.Comment)
+		if err := co.WriteRaw(c); nil != err {
+			return err
+		}
+		s.Rejected += 1
+		s.row += 1 // test cases are numbered including those rejected
+		return x(line, bytes.TrimRight(raw[len(c):], "\r\n"), reason)
+	}
+	// write writes the record q returned by a handler given rec, which was read
+	// from raw bytes that are written instead if q is unmodified. Otherwise, only
+	// the blank and comment lines preceding rec are retained.
+	write := func(q, rec []string, raw []byte) error {
+		if equal(q, rec) {
+			return co.WriteRaw(raw)
+		}
+		if c := comments(raw, s.Dialect.Comment); len(c) > 0 {
+			if err := co.WriteRaw(c); nil != err {
+				return err
+			}
+		}
+		return co.Write(q)
+	}
 	defer func() {
 		co.Flush()
 		if err == nil {
@@ -62,28 +161,88 @@ func (s *Suite) filter(r io.Reader, w io.Writer, d, h RecordHandler) (err error)
 	lineNo := 0
 	for {
 
+		if err := ctx.Err(); nil != err {
+			return err
+		}
+
 		rec, err := ci.Read()
 		if err == io.EOF {
+			// retain any blank and comment lines following the last record
+			if tail := in.take(ci.InputOffset()); len(tail) > 0 {
+				return co.WriteRaw(tail)
+			}
 			break
 		}
+		raw := in.take(ci.InputOffset())
 		if nil != err {
-			return err
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return err
+			}
+			if nil == x || lineNo == 0 {
+				c := comments(raw, s.Dialect.Comment)
+				row := 0 // the header row
+				if lineNo > 0 {
+					row = s.row + 1
+				}
+				e := &Error{
+					Row:    row,
+					Line:   perr.Line,
+					Column: perr.Column,
+					Text:   lineOf(raw[len(c):], perr.Line-perr.StartLine),
+					Err:    perr.Err,
+				}
+				if lineNo > 0 {
+					e.Col, e.Value = fieldAt(raw[len(c):],
+						perr.Line-perr.StartLine, perr.Column, s.Dialect.Comma)
+					if e.Col < len(s.header) {
+						e.Field = s.header[e.Col]
+					}
+				}
+				return e
+			}
+			if err := rejectRow(perr.StartLine, raw, perr.Err); nil != err {
+				return err
+			}
+			continue
+		}
+		if lineNo == 0 {
+			width = len(rec)
+			s.header = append([]string(nil), rec...)
+		} else if nil != x && len(rec) != width {
+			line, _ := ci.FieldPos(0)
+			reason := fmt.Errorf("expected %d fields, found %d", width, len(rec))
+			if err := rejectRow(line, raw, reason); nil != err {
+				return err
+			}
+			continue
 		}
 		lineNo += 1
+		// handlers may modify the given record in place
+		orig := append([]string(nil), rec...)
+		if lineNo > 1 {
+			s.row += 1
+		}
+		s.cur, s.fields = ci, len(rec)
+		s.line, _ = ci.FieldPos(0)
+		s.raw = raw[len(comments(raw, s.Dialect.Comment)):]
 
 		if nil == d {
-			d = func(rec []string) (q []string, skip, stop bool) {
-				return rec, true, false
+			d = func(rec []string) (q []string, skip, stop bool, err error) {
+				return rec, true, false, nil
 			}
 		}
 
 		switch lineNo {
 		case 1:
-			q, skip, stop := d(rec)
+			q, skip, stop, err := d(rec)
+			if nil != err {
+				return s.fail(err)
+			}
 			if stop {
 				return nil
 			} else if !skip {
-				if err := co.Write(q); nil != err {
+				if err := write(q, orig, raw); nil != err {
 					return err
 				}
 				break // out of switch block
@@ -93,12 +252,15 @@ func (s *Suite) filter(r io.Reader, w io.Writer, d, h RecordHandler) (err error)
 			fallthrough
 
 		default:
-			q, skip, stop := h(rec)
+			q, skip, stop, err := h(rec)
 			s.Processed += 1
+			if nil != err {
+				return s.fail(err)
+			}
 			if stop {
 				return nil
 			} else if !skip {
-				if err := co.Write(q); nil != err {
+				if err := write(q, orig, raw); nil != err {
 					return err
 				}
 				s.Filtered += 1
@@ -108,3 +270,68 @@ func (s *Suite) filter(r io.Reader, w io.Writer, d, h RecordHandler) (err error)
 
 	return
 }
+
+// fail returns the given error returned by a handler as an *Error located at
+// the record being processed.
+func (s *Suite) fail(err error) error {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Err: err}
+	}
+	s.Locate(e)
+	return e
+}
+
+// recorder is a reader retaining everything read from r until taken.
+type recorder struct {
+	r    io.Reader
+	buf  []byte
+	base int64 // input offset of buf[0]
+}
+
+func (t *recorder) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.buf = append(t.buf, p[:n]...)
+	return n, err
+}
+
+// take returns the bytes read from the last offset taken up to the given
+// offset, which are then no longer retained.
+func (t *recorder) take(off int64) []byte {
+	n := off - t.base
+	raw := t.buf[:n:n]
+	t.buf, t.base = t.buf[n:], off
+	return raw
+}
+
+// comments returns the blank lines and lines beginning with comment (if not 0)
+// at the beginning of raw.
+func comments(raw []byte, comment rune) []byte {
+	n := 0
+	for n < len(raw) {
+		line := raw[n:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i+1]
+		} else {
+			break // the record itself
+		}
+		t := bytes.TrimSpace(line)
+		if len(t) > 0 && (comment == 0 || !bytes.HasPrefix(t, []byte(string(comment)))) {
+			break
+		}
+		n += len(line)
+	}
+	return raw[:n]
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,201 @@
+package suite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ardnew/csm/suite/dialect"
+)
+
+const header = "MDS,GROSS_WEIGHT,RCR,[outext]VR,[out]VR"
+
+// keep retains every record without modification.
+func keep(rec []string) ([]string, bool, bool, error) { return rec, false, false, nil }
+
+func process(t *testing.T, in string, opts dialect.Options, handle RecordHandler, reject RejectHandler) (string, *Suite, error) {
+	t.Helper()
+	var b bytes.Buffer
+	s, err := Process(context.Background(), strings.NewReader(in), &b, opts, keep, handle, reject)
+	return b.String(), s, err
+}
+
+func TestProcessRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		opts dialect.Options
+	}{
+		{"plain", header + "\n0,200000,1,142.26,142.3\n1,210000,2,145.04,145.1\n", dialect.Options{}},
+		{"no final newline", header + "\n0,200000,1,142.26,142.3", dialect.Options{}},
+		{"bom crlf", dialect.BOM + header + "\r\n0,200000,1,142.26,142.3\r\n", dialect.Options{}},
+		{"quote all", `"MDS","RCR"` + "\n" + `"0","1"` + "\n", dialect.Options{}},
+		{"mixed quoting", header + "\n\"0\",200000,1,142.26,\"142.3\"\n", dialect.Options{}},
+		{"quoted newline", "MDS,NOTE\n0,\"two\nlines\"\n", dialect.Options{}},
+		{"semicolon", "MDS;WEIGHT\n0;1,5\n", dialect.Options{}},
+		{"windows-1252", "MDS,NOTE\n0,caf\xe9 \x80\n", dialect.Options{}},
+		{"comments", "# suite\n" + header + "\n# first\n\n0,200000,1,142.26,142.3\n# last\n",
+			dialect.Options{Comment: '#'}},
+	} {
+		out, s, err := process(t, tc.in, tc.opts, keep, nil)
+		if nil != err {
+			t.Fatalf("%s: Process(): %v", tc.name, err)
+		}
+		if out != tc.in {
+			t.Errorf("%s: Process() wrote %q, want %q", tc.name, out, tc.in)
+		}
+		if s.Processed != s.Filtered {
+			t.Errorf("%s: retained %d of %d records", tc.name, s.Filtered, s.Processed)
+		}
+	}
+}
+
+func TestProcessModified(t *testing.T) {
+	in := dialect.BOM + "# suite\r\n\"MDS\",\"RCR\"\r\n# first\r\n\"0\",\"1\"\r\n\"1\",\"2\"\r\n"
+	want := dialect.BOM + "# suite\r\n\"MDS\",\"RCR\"\r\n# first\r\n\"0\",\"WET\"\r\n\"1\",\"2\"\r\n"
+	out, _, err := process(t, in, dialect.Options{Comment: '#'},
+		func(rec []string) ([]string, bool, bool, error) {
+			if rec[0] == "0" {
+				rec[1] = "WET"
+			}
+			return rec, false, false, nil
+		}, nil)
+	if nil != err {
+		t.Fatalf("Process(): %v", err)
+	}
+	if out != want {
+		t.Errorf("Process() wrote %q, want %q", out, want)
+	}
+}
+
+func TestProcessSkip(t *testing.T) {
+	in := "MDS,RCR\n# zero\n0,1\n# one\n1,2\n"
+	want := "MDS,RCR\n# one\n1,2\n"
+	out, s, err := process(t, in, dialect.Options{Comment: '#'},
+		func(rec []string) ([]string, bool, bool, error) {
+			return rec, rec[0] == "0", false, nil
+		}, nil)
+	if nil != err {
+		t.Fatalf("Process(): %v", err)
+	}
+	if out != want {
+		t.Errorf("Process() wrote %q, want %q", out, want)
+	}
+	if s.Processed != 2 || s.Filtered != 1 {
+		t.Errorf("retained %d of %d records, want 1 of 2", s.Filtered, s.Processed)
+	}
+}
+
+func TestProcessReject(t *testing.T) {
+	in := header + "\n0,200000,1,142.26,142.3\n1,21\"0000,2,145.04,145.1\n2,220000\n3,230000,1,150.5,150.5\n"
+	want := header + "\n0,200000,1,142.26,142.3\n3,230000,1,150.5,150.5\n"
+	var line []int
+	var raw []string
+	out, s, err := process(t, in, dialect.Options{}, keep,
+		func(n int, r []byte, reason error) error {
+			line, raw = append(line, n), append(raw, string(r))
+			return nil
+		})
+	if nil != err {
+		t.Fatalf("Process(): %v", err)
+	}
+	if out != want {
+		t.Errorf("Process() wrote %q, want %q", out, want)
+	}
+	if s.Rejected != 2 || len(line) != 2 || line[0] != 3 || line[1] != 4 {
+		t.Fatalf("rejected lines %v (%d), want [3 4]", line, s.Rejected)
+	}
+	if raw[1] != "2,220000" {
+		t.Errorf("rejected %q, want %q", raw[1], "2,220000")
+	}
+}
+
+func TestProcessRejectRow(t *testing.T) {
+	in := header + "\n0,200000,1,142.26,142.3\n2,220000\n3,230000,1,150.5,150.5\n"
+	fail := errors.New("failed")
+	_, _, err := process(t, in, dialect.Options{}, func(rec []string) ([]string, bool, bool, error) {
+		if rec[0] == "3" {
+			return rec, true, true, fail
+		}
+		return rec, false, false, nil
+	}, func(int, []byte, error) error { return nil })
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("Process() = %v, want *Error", err)
+	}
+	if e.Row != 3 || e.Line != 4 {
+		t.Errorf("error at row %d, line %d, want row 3, line 4", e.Row, e.Line)
+	}
+}
+
+func TestProcessParseError(t *testing.T) {
+	in := header + "\n0,200000,1,142.26,142.3\n1,21\"0000,2,145.04,145.1\n"
+	_, _, err := process(t, in, dialect.Options{}, keep, nil)
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("Process() = %v, want *Error", err)
+	}
+	if e.Row != 2 || e.Line != 3 || e.Column != 5 {
+		t.Errorf("error at row %d, %d:%d, want row 2, 3:5", e.Row, e.Line, e.Column)
+	}
+	if e.Col != 1 || e.Field != "GROSS_WEIGHT" || e.Value != `21"0000` {
+		t.Errorf("error in column %d %s = %q, want 1 GROSS_WEIGHT = %q",
+			e.Col, e.Field, e.Value, `21"0000`)
+	}
+}
+
+func TestProcessHandlerError(t *testing.T) {
+	in := header + "\n0,200000,1,142.26,142.3\n# note\n1,210000,2,145.04,145.1\n"
+	fail := errors.New("failed")
+	_, _, err := process(t, in, dialect.Options{Comment: '#'}, func(rec []string) ([]string, bool, bool, error) {
+		if rec[0] == "1" {
+			return rec, true, true, &Error{Col: 2, Field: "RCR", Value: rec[2], Err: fail}
+		}
+		return rec, false, false, nil
+	}, nil)
+	var e *Error
+	if !errors.As(err, &e) || !errors.Is(err, fail) {
+		t.Fatalf("Process() = %v, want *Error", err)
+	}
+	if e.Row != 2 || e.Line != 4 || e.Column != 10 {
+		t.Errorf("error at row %d, %d:%d, want row 2, 4:10", e.Row, e.Line, e.Column)
+	}
+	if e.Text != "1,210000,2,145.04,145.1" {
+		t.Errorf("error text %q", e.Text)
+	}
+}
+
+func TestNewKeepsOutputOnError(t *testing.T) {
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "in.csv"), filepath.Join(dir, "out.csv")
+	if err := os.WriteFile(in, []byte(header+"\n0,200000,1,142.26,142.3\n"), 0644); nil != err {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(out, []byte("original"), 0644); nil != err {
+		t.Fatal(err)
+	}
+	_, err := New(in, out, dialect.Options{}, keep, func(rec []string) ([]string, bool, bool, error) {
+		return rec, true, true, errors.New("failed")
+	}, nil)
+	if nil == err {
+		t.Fatal("New() succeeded, want error")
+	}
+	if b, _ := os.ReadFile(out); string(b) != "original" {
+		t.Errorf("output replaced with %q", b)
+	}
+	if ent, _ := os.ReadDir(dir); len(ent) != 2 {
+		t.Errorf("found %d files, want temporary file removed", len(ent))
+	}
+
+	s, err := New(in, out, dialect.Options{}, keep, keep, nil)
+	if nil != err {
+		t.Fatalf("New(): %v", err)
+	}
+	if b, _ := os.ReadFile(out); string(b) != header+"\n0,200000,1,142.26,142.3\n" || s.Filtered != 1 {
+		t.Errorf("output %q, want input", b)
+	}
+}
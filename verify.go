@@ -0,0 +1,137 @@
+package csm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ardnew/csm/log"
+	"github.com/ardnew/csm/suite/compare"
+)
+
+// ResultMember returns the name of the test case file verified by the results
+// file at path, which is the first dot-separated component of its file name
+// (e.g., "takeoff" for "takeoff.results.csv") with the TestcaseExt suffix.
+func ResultMember(path string) string {
+	base := filepath.Base(path)
+	if n := strings.Index(base, "."); n > 0 {
+		base = base[:n]
+	}
+	return base + TestcaseExt
+}
+
+// Verify compares the computed outputs of each results file in opts.Results
+// with the expected outputs of the test case file it verifies, within the
+// tolerances given by opts.Tolerances. Each failed test case is printed with
+// the outputs that differ, followed by a summary of each file. It returns true
+// if and only if every test case has results and all of them passed. An output
+// missing from a results file cannot be verified, so every test case of that
+// file fails.
+func (c *CSM) Verify(opts Options) (bool, error) {
+	member := make([]string, 0, len(opts.Results))
+	for m := range opts.Results {
+		member = append(member, m)
+	}
+	sortMembers(member)
+
+	pass := true
+	var total, failed int
+	for _, m := range member {
+		path := opts.Results[m]
+		log.Msg(log.Info, "verify", "%q -> %q", path, m)
+		exp, err := readRecords(filepath.Join(c.csvPath, m), c.dialect)
+		if nil != err {
+			return false, err
+		}
+		got, err := readRecords(path, c.dialect)
+		if nil != err {
+			return false, err
+		}
+
+		res := compare.Verify(exp, got, opts.Tolerances, opts.JoinRows)
+		var missing []string
+		for _, n := range res.Missing {
+			if strings.HasPrefix(n, OutPrefix) || strings.HasPrefix(n, ExtPrefix) {
+				missing = append(missing, n)
+			} else {
+				log.Msg(log.Warn, "verify", "%s: results missing input column: %q", m, n)
+			}
+		}
+		if len(missing) > 0 {
+			log.Msg(log.Error, "verify", "%s: results missing %d output columns (not verified)",
+				m, len(missing))
+		}
+		if len(missing)+len(res.Removed)+len(res.Changed) > 0 {
+			fmt.Fprintln(os.Stdout, "==", m)
+		}
+		for _, n := range missing {
+			fmt.Fprintf(os.Stdout, "  ! %s: FAIL: not verified (no results)\n", n)
+		}
+		for _, d := range res.Removed {
+			fmt.Fprintf(os.Stdout, "  ? row %d: no results: %s\n", d.Row, strings.Join(d.Key, ","))
+		}
+		for _, d := range res.Changed {
+			fmt.Fprintf(os.Stdout, "  ! row %d: FAIL: %s\n", d.Row, strings.Join(d.Key, ","))
+			for _, x := range d.Changes {
+				fmt.Fprintf(os.Stdout, "      %s\n", x)
+			}
+		}
+		for _, d := range res.Added {
+			log.Msg(log.Warn, "verify", "%s: results row %d has no test case", m, d.Other)
+		}
+
+		passed, fail := res.Unchanged, len(res.Changed)
+		if len(missing) > 0 {
+			passed, fail = 0, fail+res.Unchanged
+		}
+		n := passed + fail + len(res.Removed)
+		total, failed = total+n, failed+fail+len(res.Removed)
+		if fail+len(res.Removed) > 0 {
+			pass = false
+		}
+		log.Msg(log.Info, "verify", "%s: %d passed, %d failed, %d without results",
+			m, passed, fail, len(res.Removed))
+	}
+
+	if pass {
+		log.Msg(log.Info, "verify", "PASS (%d test cases)", total)
+	} else {
+		log.Msg(log.Warn, "verify", "FAIL (%d of %d test cases)", failed, total)
+	}
+	return pass, nil
+}
+
+// Results maps the name of each test case file to the path of a results file
+// containing its computed outputs. It implements flag.Value, given as either
+// "member=path" or only "path", in which case the member is determined by
+// ResultMember.
+type Results map[string]string
+
+func (r Results) String() string {
+	rs := make([]string, 0, len(r))
+	for m, p := range r {
+		rs = append(rs, m+"="+p)
+	}
+	sort.Strings(rs)
+	return strings.Join(rs, ",")
+}
+
+func (r *Results) Set(s string) error {
+	if *r == nil {
+		*r = Results{}
+	}
+	member, path := "", strings.TrimSpace(s)
+	if n := strings.Index(s, "="); n > 0 {
+		member, path = strings.TrimSpace(s[:n]), strings.TrimSpace(s[n+1:])
+		member = strings.TrimSuffix(member, TestcaseExt) + TestcaseExt
+	} else {
+		member = ResultMember(path)
+	}
+	if path == "" {
+		return fmt.Errorf("unrecognized results file: %q", s)
+	}
+	(*r)[member] = path
+	return nil
+}